@@ -0,0 +1,5 @@
+// +k8s:deepcopy-gen=package
+// +groupName=resize.external-resizer.io
+
+// Package v1alpha1 contains API types of the resize.external-resizer.io group.
+package v1alpha1
@@ -0,0 +1,44 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ResizePolicy limits how PVCs in its namespace can be resized.
+// If there are multiple policies in a namespace, a resize request must satisfy all of them.
+type ResizePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ResizePolicySpec `json:"spec"`
+}
+
+// ResizePolicySpec describes limits of resize requests, unset fields mean no limit.
+type ResizePolicySpec struct {
+	// MaxSize is the max size a PVC can be resized to.
+	// +optional
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+	// MaxIncrement is the max size a PVC can be expanded by in one resize operation.
+	// +optional
+	MaxIncrement *resource.Quantity `json:"maxIncrement,omitempty"`
+	// MinInterval is the min interval between two resize operations of a PVC.
+	// +optional
+	MinInterval *metav1.Duration `json:"minInterval,omitempty"`
+	// StorageClassNames are StorageClasses whose PVCs are allowed to be resized, empty means all.
+	// +optional
+	StorageClassNames []string `json:"storageClassNames,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ResizePolicyList is a list of ResizePolicy.
+type ResizePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ResizePolicy `json:"items"`
+}
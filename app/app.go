@@ -0,0 +1,265 @@
+// Package app builds resizer binaries, it creates the kube client, leader election, metrics, tracing,
+// the admission webhook and the autoscaler from Options, so that a resizer binary only provides its backends.
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mlmhl/external-resizer/autoscaler"
+	"github.com/mlmhl/external-resizer/client/clientset/versioned"
+	resizeinformers "github.com/mlmhl/external-resizer/client/informers/externalversions"
+	resizelisters "github.com/mlmhl/external-resizer/client/listers/resize/v1alpha1"
+	"github.com/mlmhl/external-resizer/controller"
+	"github.com/mlmhl/external-resizer/logging"
+	"github.com/mlmhl/external-resizer/tracing"
+	"github.com/mlmhl/external-resizer/util"
+	"github.com/mlmhl/external-resizer/webhook"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Main is the entry point of resizer binaries. It parses the standard flags registered by Options.AddFlags,
+// then runs the resize controller hosting backends until SIGINT or SIGTERM is received. The process exits
+// with util.LeadershipLostExitCode if leadership is lost, or 1 on other errors.
+func Main(backends ...controller.Backend) {
+	options := NewOptions()
+	options.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	err := Run(ctx, options, backends)
+	if err != nil {
+		logging.Logger().Error(err, "Exiting")
+	}
+	glog.Flush()
+	switch {
+	case err == util.ErrLeadershipLost:
+		os.Exit(util.LeadershipLostExitCode)
+	case err != nil:
+		os.Exit(1)
+	}
+}
+
+// Run builds the resize controller hosting backends by options and runs it until ctx is done, nil is returned then.
+// An error is returned if options are invalid, any component can't be created, or the controller or the admission
+// webhook server stops unexpectedly, see controller.ResizeController.Run.
+func Run(ctx context.Context, options *Options, backends []controller.Backend) error {
+	if len(backends) == 0 {
+		return fmt.Errorf("at least one backend must be provided")
+	}
+	// Keep options set by flags as defaults of fields omitted in the configuration file when it's reloaded.
+	base := options
+	var (
+		configuration *Configuration
+		configData    []byte
+	)
+	if options.ConfigFile != "" {
+		var err error
+		configuration, configData, err = loadConfiguration(options.ConfigFile, options)
+		if err != nil {
+			return err
+		}
+		options = configuration.applyTo(options)
+		if err := logging.SetVerbosity(configuration.Logging.Verbosity); err != nil {
+			return fmt.Errorf("failed to set log verbosity: %v", err)
+		}
+	}
+	if err := options.Validate(); err != nil {
+		return fmt.Errorf("invalid options: %v", err)
+	}
+
+	logger, err := logging.New(options.LogFormat)
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %v", err)
+	}
+	logging.SetLogger(logger)
+
+	name := options.Name
+	if name == "" {
+		name = backends[0].Name
+	}
+
+	var config *rest.Config
+	if options.Master != "" || options.KubeConfig != "" {
+		config, err = clientcmd.BuildConfigFromFlags(options.Master, options.KubeConfig)
+	} else {
+		config, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create config: %v", err)
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %v", err)
+	}
+
+	id := options.Identity
+	if len(id) == 0 {
+		id = fmt.Sprintf("%s-%s", name, uuid.NewUUID())
+	}
+
+	tracingConfig := options.Tracing
+	if tracingConfig.ServiceName == "" {
+		tracingConfig.ServiceName = name
+	}
+	shutdownTracing, err := tracing.Setup(ctx, &tracingConfig)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	registry := controller.NewRegistry()
+	for _, b := range backends {
+		if err := registry.Register(b); err != nil {
+			return fmt.Errorf("failed to register backend: %v", err)
+		}
+	}
+
+	controllerOptions := []controller.Option{
+		controller.WithWorkers(options.Workers),
+		controller.WithShutdownGracePeriod(options.ShutdownGracePeriod),
+		controller.WithBackoff(options.Backoff),
+		controller.WithRateLimit(options.RateLimit),
+		controller.WithStorageClassResizeTimeouts(options.StorageClassResizeTimeouts),
+	}
+	if options.LeaderElection.Enabled {
+		controllerOptions = append(controllerOptions, controller.WithLeaderElection(&util.LeaderElectionConfig{
+			Identity:      id,
+			LockName:      name,
+			Namespace:     options.LeaderElection.Namespace,
+			LockType:      options.LeaderElection.LockType,
+			RetryPeriod:   options.LeaderElection.RetryPeriod,
+			LeaseDuration: options.LeaderElection.LeaseDuration,
+			RenewDeadLine: options.LeaderElection.RenewDeadline,
+			ReElect:       options.LeaderElection.ReElect,
+		}))
+	}
+	if options.Metrics.Enabled {
+		metricConfig := options.Metrics.MetricConfig
+		controllerOptions = append(controllerOptions, controller.WithMetrics(&metricConfig))
+	}
+	if options.DryRun {
+		controllerOptions = append(controllerOptions, controller.WithDryRun())
+	}
+	// Informers are shared by the controller and the autoscaler.
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, options.ResyncPeriod)
+	controllerOptions = append(controllerOptions, controller.WithInformerFactory(informerFactory))
+	if options.Autoscaler.Enabled {
+		var source autoscaler.StatsSource
+		if options.Autoscaler.Source == AutoscalerSourcePrometheus {
+			source = autoscaler.NewPrometheusStatsSource(options.Autoscaler.PrometheusURL, nil)
+		} else {
+			source = autoscaler.NewKubeletStatsSource(kubeClient)
+		}
+		// Run the autoscaler as a leader task so that only one of the replicas autoscales PVCs.
+		controllerOptions = append(controllerOptions,
+			controller.WithLeaderTask(autoscaler.New(kubeClient, informerFactory.Core().V1().PersistentVolumeClaims(), source,
+				options.Autoscaler.Interval).Run))
+	}
+
+	var policyClient versioned.Interface
+	if options.EnableResizePolicy {
+		policyClient, err = versioned.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create resize policy client: %v", err)
+		}
+		controllerOptions = append(controllerOptions, controller.WithResizePolicies(policyClient))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The controller is stopped if the admission webhook server fails.
+	webhookErrCh := make(chan error, 1)
+	if options.Webhook.Enabled {
+		server, err := newWebhookServer(ctx, options, registry, kubeClient, policyClient)
+		if err != nil {
+			return err
+		}
+		go func() {
+			err := server.Run(ctx.Done())
+			if err != nil {
+				cancel()
+			}
+			webhookErrCh <- err
+		}()
+	} else {
+		webhookErrCh <- nil
+	}
+
+	controllerOptions = append(controllerOptions, options.ControllerOptions...)
+	rc := controller.NewResizeController(id, registry, kubeClient, options.ResyncPeriod, options.ResizeTimeout,
+		controllerOptions...)
+	if configuration != nil {
+		go newConfigWatcher(base.ConfigFile, base, configuration, configData, rc).run(ctx)
+	}
+	err = rc.Run(ctx)
+
+	// Wait for the admission webhook server to shut down.
+	cancel()
+	if webhookErr := <-webhookErrCh; webhookErr != nil {
+		return webhookErr
+	}
+	return err
+}
+
+// newWebhookServer creates the admission webhook server validating resize requests against backends
+// of the registry, once its caches are synced. The caches are stopped when ctx is done.
+func newWebhookServer(
+	ctx context.Context,
+	options *Options,
+	registry *controller.Registry,
+	kubeClient kubernetes.Interface,
+	policyClient versioned.Interface) (*webhook.Server, error) {
+	// The webhook is served by all replicas, so it has its own caches rather than sharing the controller's,
+	// which are only synced while we are the leader.
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, options.ResyncPeriod)
+	pvInformer := informerFactory.Core().V1().PersistentVolumes()
+	scInformer := informerFactory.Storage().V1().StorageClasses()
+	cacheSynced := []cache.InformerSynced{pvInformer.Informer().HasSynced, scInformer.Informer().HasSynced}
+	var policyLister resizelisters.ResizePolicyLister
+	if policyClient != nil {
+		policyInformerFactory := resizeinformers.NewSharedInformerFactory(policyClient, options.ResyncPeriod)
+		policyInformer := policyInformerFactory.Resize().V1alpha1().ResizePolicies()
+		policyLister = policyInformer.Lister()
+		cacheSynced = append(cacheSynced, policyInformer.Informer().HasSynced)
+		policyInformerFactory.Start(ctx.Done())
+	}
+	informerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), cacheSynced...) {
+		return nil, fmt.Errorf("cannot sync caches of the admission webhook")
+	}
+
+	webhookConfig := options.Webhook.Config
+	webhookConfig.DryRun = options.DryRun
+	server, err := webhook.NewServer(&webhookConfig,
+		controller.NewValidator(registry, pvInformer.Lister(), scInformer.Lister(), policyLister))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admission webhook server: %v", err)
+	}
+	if caBundle := server.CABundle(); caBundle != nil {
+		logging.Logger().Info("Admission webhook serves a self-signed certificate",
+			"caBundle", base64.StdEncoding.EncodeToString(caBundle))
+	}
+	return server, nil
+}
@@ -0,0 +1,300 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/mlmhl/external-resizer/controller"
+	"github.com/mlmhl/external-resizer/logging"
+	"github.com/mlmhl/external-resizer/util"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// APIVersion and Kind of the configuration file.
+const (
+	ConfigAPIVersion = "resizer.external-resizer.io/v1alpha1"
+	ConfigKind       = "ResizerConfiguration"
+)
+
+// Configuration is the content of the configuration file in YAML or JSON. Fields omitted in the file
+// default to values of the corresponding flags. Workers, Retry, RateLimit and Logging.Verbosity are
+// applied while running once the file is changed, other fields take effect after restart.
+type Configuration struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	Workers             int                         `json:"workers"`
+	ResyncPeriod        metav1.Duration             `json:"resyncPeriod"`
+	ResizeTimeout       metav1.Duration             `json:"resizeTimeout"`
+	ShutdownGracePeriod metav1.Duration             `json:"shutdownGracePeriod"`
+	Retry               RetryConfiguration          `json:"retry"`
+	RateLimit           RateLimitConfiguration      `json:"rateLimit"`
+	LeaderElection      LeaderElectionConfiguration `json:"leaderElection"`
+	Metrics             MetricsConfiguration        `json:"metrics"`
+	Logging             LoggingConfiguration        `json:"logging"`
+	// StorageClasses override settings for PVCs of each StorageClass.
+	StorageClasses []StorageClassConfiguration `json:"storageClasses,omitempty"`
+}
+
+// RetryConfiguration configures the backoff of failed resize operations, see controller.BackoffConfig.
+type RetryConfiguration struct {
+	IntervalStart metav1.Duration `json:"intervalStart"`
+	IntervalMax   metav1.Duration `json:"intervalMax"`
+	Jitter        float64         `json:"jitter"`
+}
+
+// RateLimitConfiguration configures the overall rate limit of each backend, see controller.RateLimitConfig.
+type RateLimitConfiguration struct {
+	QPS   float64 `json:"qps"`
+	Burst int     `json:"burst"`
+}
+
+// LeaderElectionConfiguration configures leader election, see LeaderElectionOptions.
+type LeaderElectionConfiguration struct {
+	Enabled       bool            `json:"enabled"`
+	Namespace     string          `json:"namespace"`
+	LockType      string          `json:"lockType"`
+	RetryPeriod   metav1.Duration `json:"retryPeriod"`
+	LeaseDuration metav1.Duration `json:"leaseDuration"`
+	RenewDeadline metav1.Duration `json:"renewDeadline"`
+	ReElect       bool            `json:"reElect"`
+}
+
+// MetricsConfiguration configures the metrics server, see MetricsOptions.
+type MetricsConfiguration struct {
+	Enabled     bool   `json:"enabled"`
+	Path        string `json:"path"`
+	Address     string `json:"address"`
+	CertFile    string `json:"certFile"`
+	KeyFile     string `json:"keyFile"`
+	EnablePprof bool   `json:"enablePprof"`
+}
+
+// LoggingConfiguration configures logs, Verbosity is the -v flag of glog.
+type LoggingConfiguration struct {
+	Format    string `json:"format"`
+	Verbosity int    `json:"verbosity"`
+}
+
+// StorageClassConfiguration overrides settings for PVCs of the StorageClass.
+type StorageClassConfiguration struct {
+	Name string `json:"name"`
+	// ResizeTimeout overrides the resize timeout, 0 means no timeout.
+	ResizeTimeout metav1.Duration `json:"resizeTimeout"`
+}
+
+// newConfiguration creates a Configuration from options, which provides defaults of fields omitted in the file.
+func newConfiguration(o *Options) *Configuration {
+	c := &Configuration{
+		APIVersion:          ConfigAPIVersion,
+		Kind:                ConfigKind,
+		Workers:             o.Workers,
+		ResyncPeriod:        metav1.Duration{Duration: o.ResyncPeriod},
+		ResizeTimeout:       metav1.Duration{Duration: o.ResizeTimeout},
+		ShutdownGracePeriod: metav1.Duration{Duration: o.ShutdownGracePeriod},
+		Retry: RetryConfiguration{
+			IntervalStart: metav1.Duration{Duration: o.Backoff.Base},
+			IntervalMax:   metav1.Duration{Duration: o.Backoff.Max},
+			Jitter:        o.Backoff.Jitter,
+		},
+		RateLimit: RateLimitConfiguration{
+			QPS:   o.RateLimit.QPS,
+			Burst: o.RateLimit.Burst,
+		},
+		LeaderElection: LeaderElectionConfiguration{
+			Enabled:       o.LeaderElection.Enabled,
+			Namespace:     o.LeaderElection.Namespace,
+			LockType:      o.LeaderElection.LockType,
+			RetryPeriod:   metav1.Duration{Duration: o.LeaderElection.RetryPeriod},
+			LeaseDuration: metav1.Duration{Duration: o.LeaderElection.LeaseDuration},
+			RenewDeadline: metav1.Duration{Duration: o.LeaderElection.RenewDeadline},
+			ReElect:       o.LeaderElection.ReElect,
+		},
+		Metrics: MetricsConfiguration{
+			Enabled:     o.Metrics.Enabled,
+			Path:        o.Metrics.Path,
+			Address:     o.Metrics.Address,
+			CertFile:    o.Metrics.CertFile,
+			KeyFile:     o.Metrics.KeyFile,
+			EnablePprof: o.Metrics.EnablePprof,
+		},
+		Logging: LoggingConfiguration{
+			Format:    o.LogFormat,
+			Verbosity: logging.Verbosity(),
+		},
+	}
+	for name, timeout := range o.StorageClassResizeTimeouts {
+		c.StorageClasses = append(c.StorageClasses, StorageClassConfiguration{
+			Name:          name,
+			ResizeTimeout: metav1.Duration{Duration: timeout},
+		})
+	}
+	// Keep the order stable, so that configurations parsed from the same file are equal.
+	sort.Slice(c.StorageClasses, func(i, j int) bool {
+		return c.StorageClasses[i].Name < c.StorageClasses[j].Name
+	})
+	return c
+}
+
+// loadConfiguration reads the configuration file at path, fields omitted in the file default to options.
+func loadConfiguration(path string, o *Options) (*Configuration, []byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read configuration file %s failed: %v", path, err)
+	}
+	c, err := parseConfiguration(data, o)
+	if err != nil {
+		return nil, data, fmt.Errorf("invalid configuration file %s: %v", path, err)
+	}
+	return c, data, nil
+}
+
+func parseConfiguration(data []byte, o *Options) (*Configuration, error) {
+	// Check the version before decoding, as other versions may have different fields.
+	var meta struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	if meta.APIVersion != ConfigAPIVersion || meta.Kind != ConfigKind {
+		return nil, fmt.Errorf("unsupported apiVersion %q and kind %q, must be %s %s",
+			meta.APIVersion, meta.Kind, ConfigAPIVersion, ConfigKind)
+	}
+
+	c := newConfiguration(o)
+	// Omitted fields keep their defaults, unknown fields are rejected to catch typos.
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Configuration) validate() error {
+	if c.Workers <= 0 {
+		return fmt.Errorf("workers must be positive")
+	}
+	if c.ResyncPeriod.Duration <= 0 {
+		return fmt.Errorf("resyncPeriod must be positive")
+	}
+	if c.ResizeTimeout.Duration < 0 {
+		return fmt.Errorf("resizeTimeout must not be negative")
+	}
+	if c.ShutdownGracePeriod.Duration < 0 {
+		return fmt.Errorf("shutdownGracePeriod must not be negative")
+	}
+	if c.Retry.IntervalStart.Duration <= 0 {
+		return fmt.Errorf("retry.intervalStart must be positive")
+	}
+	if c.Retry.IntervalMax.Duration < c.Retry.IntervalStart.Duration {
+		return fmt.Errorf("retry.intervalMax must not be smaller than retry.intervalStart")
+	}
+	if c.Retry.Jitter < 0 {
+		return fmt.Errorf("retry.jitter must not be negative")
+	}
+	if c.RateLimit.QPS <= 0 {
+		return fmt.Errorf("rateLimit.qps must be positive")
+	}
+	if c.RateLimit.Burst <= 0 {
+		return fmt.Errorf("rateLimit.burst must be positive")
+	}
+	if c.LeaderElection.Enabled {
+		if !containsString(util.LeaderElectionLockTypes, c.LeaderElection.LockType) {
+			return fmt.Errorf("leaderElection.lockType must be one of %v", util.LeaderElectionLockTypes)
+		}
+		if c.LeaderElection.RenewDeadline.Duration >= c.LeaderElection.LeaseDuration.Duration {
+			return fmt.Errorf("leaderElection.renewDeadline must be shorter than leaderElection.leaseDuration")
+		}
+	}
+	if c.Metrics.Enabled && c.Metrics.Address == "" {
+		return fmt.Errorf("metrics.address can't be empty if metrics are enabled")
+	}
+	if !containsString(logging.Formats, c.Logging.Format) {
+		return fmt.Errorf("logging.format must be one of %v", logging.Formats)
+	}
+	if c.Logging.Verbosity < 0 {
+		return fmt.Errorf("logging.verbosity must not be negative")
+	}
+	names := make(map[string]bool)
+	for i, sc := range c.StorageClasses {
+		if sc.Name == "" {
+			return fmt.Errorf("storageClasses[%d].name can't be empty", i)
+		}
+		if names[sc.Name] {
+			return fmt.Errorf("storageClasses[%d].name %s is duplicated", i, sc.Name)
+		}
+		names[sc.Name] = true
+		if sc.ResizeTimeout.Duration < 0 {
+			return fmt.Errorf("storageClasses[%d].resizeTimeout must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// applyTo returns a copy of o overridden by the configuration.
+func (c *Configuration) applyTo(o *Options) *Options {
+	applied := *o
+	applied.Workers = c.Workers
+	applied.ResyncPeriod = c.ResyncPeriod.Duration
+	applied.ResizeTimeout = c.ResizeTimeout.Duration
+	applied.ShutdownGracePeriod = c.ShutdownGracePeriod.Duration
+	applied.Backoff = c.backoff()
+	applied.RateLimit = c.rateLimit()
+	applied.LeaderElection = LeaderElectionOptions{
+		Enabled:       c.LeaderElection.Enabled,
+		Namespace:     c.LeaderElection.Namespace,
+		LockType:      c.LeaderElection.LockType,
+		RetryPeriod:   c.LeaderElection.RetryPeriod.Duration,
+		LeaseDuration: c.LeaderElection.LeaseDuration.Duration,
+		RenewDeadline: c.LeaderElection.RenewDeadline.Duration,
+		ReElect:       c.LeaderElection.ReElect,
+	}
+	applied.Metrics = MetricsOptions{
+		Enabled: c.Metrics.Enabled,
+		MetricConfig: controller.MetricConfig{
+			Path:        c.Metrics.Path,
+			Address:     c.Metrics.Address,
+			CertFile:    c.Metrics.CertFile,
+			KeyFile:     c.Metrics.KeyFile,
+			EnablePprof: c.Metrics.EnablePprof,
+		},
+	}
+	applied.LogFormat = c.Logging.Format
+	applied.StorageClassResizeTimeouts = make(map[string]time.Duration)
+	for _, sc := range c.StorageClasses {
+		applied.StorageClassResizeTimeouts[sc.Name] = sc.ResizeTimeout.Duration
+	}
+	return &applied
+}
+
+func (c *Configuration) backoff() controller.BackoffConfig {
+	return controller.BackoffConfig{
+		Base:   c.Retry.IntervalStart.Duration,
+		Max:    c.Retry.IntervalMax.Duration,
+		Jitter: c.Retry.Jitter,
+	}
+}
+
+func (c *Configuration) rateLimit() controller.RateLimitConfig {
+	return controller.RateLimitConfig{
+		QPS:   c.RateLimit.QPS,
+		Burst: c.RateLimit.Burst,
+	}
+}
+
+func containsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfiguration(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+		check   func(t *testing.T, c *Configuration)
+	}{
+		{
+			name: "omitted fields default to options",
+			data: `
+apiVersion: resizer.external-resizer.io/v1alpha1
+kind: ResizerConfiguration
+workers: 3
+retry:
+  intervalStart: 2s
+storageClasses:
+- name: slow
+  resizeTimeout: 10m
+`,
+			check: func(t *testing.T, c *Configuration) {
+				o := NewOptions()
+				if c.Workers != 3 {
+					t.Errorf("workers = %d, want 3", c.Workers)
+				}
+				if c.Retry.IntervalStart.Duration != 2*time.Second {
+					t.Errorf("retry.intervalStart = %v, want 2s", c.Retry.IntervalStart.Duration)
+				}
+				if c.Retry.IntervalMax.Duration != o.Backoff.Max {
+					t.Errorf("retry.intervalMax = %v, want default %v", c.Retry.IntervalMax.Duration, o.Backoff.Max)
+				}
+				if c.ResyncPeriod.Duration != o.ResyncPeriod {
+					t.Errorf("resyncPeriod = %v, want default %v", c.ResyncPeriod.Duration, o.ResyncPeriod)
+				}
+				applied := c.applyTo(o)
+				if applied.Workers != 3 || applied.StorageClassResizeTimeouts["slow"] != 10*time.Minute {
+					t.Errorf("applied options = %+v", applied)
+				}
+			},
+		},
+		{
+			name: "JSON",
+			data: `{"apiVersion": "resizer.external-resizer.io/v1alpha1", "kind": "ResizerConfiguration", "rateLimit": {"qps": 5}}`,
+			check: func(t *testing.T, c *Configuration) {
+				if c.RateLimit.QPS != 5 {
+					t.Errorf("rateLimit.qps = %v, want 5", c.RateLimit.QPS)
+				}
+			},
+		},
+		{
+			name:    "unsupported apiVersion",
+			data:    "apiVersion: resizer.external-resizer.io/v1beta1\nkind: ResizerConfiguration\n",
+			wantErr: "unsupported apiVersion",
+		},
+		{
+			name:    "unsupported kind",
+			data:    "apiVersion: resizer.external-resizer.io/v1alpha1\nkind: Configuration\n",
+			wantErr: "unsupported apiVersion",
+		},
+		{
+			name:    "unknown field",
+			data:    "apiVersion: resizer.external-resizer.io/v1alpha1\nkind: ResizerConfiguration\nworker: 3\n",
+			wantErr: "unknown field",
+		},
+		{
+			name:    "invalid value",
+			data:    "apiVersion: resizer.external-resizer.io/v1alpha1\nkind: ResizerConfiguration\nworkers: 0\n",
+			wantErr: "workers must be positive",
+		},
+		{
+			name:    "malformed",
+			data:    "apiVersion: [",
+			wantErr: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := parseConfiguration([]byte(test.data), NewOptions())
+			if test.check == nil {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("parseConfiguration() error = %v, want error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseConfiguration failed: %v", err)
+			}
+			test.check(t, c)
+		})
+	}
+}
+
+func TestValidateConfiguration(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Configuration)
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			modify: func(c *Configuration) {},
+		},
+		{
+			name:    "negative resize timeout",
+			modify:  func(c *Configuration) { c.ResizeTimeout.Duration = -time.Second },
+			wantErr: "resizeTimeout",
+		},
+		{
+			name:    "zero resync period",
+			modify:  func(c *Configuration) { c.ResyncPeriod.Duration = 0 },
+			wantErr: "resyncPeriod",
+		},
+		{
+			name:    "max retry interval below the start",
+			modify:  func(c *Configuration) { c.Retry.IntervalMax.Duration = c.Retry.IntervalStart.Duration / 2 },
+			wantErr: "retry.intervalMax",
+		},
+		{
+			name:    "negative jitter",
+			modify:  func(c *Configuration) { c.Retry.Jitter = -1 },
+			wantErr: "retry.jitter",
+		},
+		{
+			name:    "zero qps",
+			modify:  func(c *Configuration) { c.RateLimit.QPS = 0 },
+			wantErr: "rateLimit.qps",
+		},
+		{
+			name:    "zero burst",
+			modify:  func(c *Configuration) { c.RateLimit.Burst = 0 },
+			wantErr: "rateLimit.burst",
+		},
+		{
+			name: "unknown lock type",
+			modify: func(c *Configuration) {
+				c.LeaderElection.Enabled = true
+				c.LeaderElection.LockType = "unknown"
+			},
+			wantErr: "leaderElection.lockType",
+		},
+		{
+			name: "renew deadline not shorter than lease duration",
+			modify: func(c *Configuration) {
+				c.LeaderElection.Enabled = true
+				c.LeaderElection.RenewDeadline = c.LeaderElection.LeaseDuration
+			},
+			wantErr: "leaderElection.renewDeadline",
+		},
+		{
+			name: "metrics without address",
+			modify: func(c *Configuration) {
+				c.Metrics.Enabled = true
+				c.Metrics.Address = ""
+			},
+			wantErr: "metrics.address",
+		},
+		{
+			name:    "unknown log format",
+			modify:  func(c *Configuration) { c.Logging.Format = "xml" },
+			wantErr: "logging.format",
+		},
+		{
+			name:    "negative verbosity",
+			modify:  func(c *Configuration) { c.Logging.Verbosity = -1 },
+			wantErr: "logging.verbosity",
+		},
+		{
+			name: "duplicated StorageClass",
+			modify: func(c *Configuration) {
+				c.StorageClasses = []StorageClassConfiguration{{Name: "sc"}, {Name: "sc"}}
+			},
+			wantErr: "storageClasses[1].name sc is duplicated",
+		},
+		{
+			name: "StorageClass without name",
+			modify: func(c *Configuration) {
+				c.StorageClasses = []StorageClassConfiguration{{}}
+			},
+			wantErr: "storageClasses[0].name",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newConfiguration(NewOptions())
+			test.modify(c)
+			err := c.validate()
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("validate() = %v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mlmhl/external-resizer/controller"
+	"github.com/mlmhl/external-resizer/logging"
+	"github.com/mlmhl/external-resizer/tracing"
+	"github.com/mlmhl/external-resizer/util"
+	"github.com/mlmhl/external-resizer/webhook"
+)
+
+// Supported stats sources of the autoscaler.
+const (
+	AutoscalerSourceKubelet    = "kubelet"
+	AutoscalerSourcePrometheus = "prometheus"
+)
+
+// Options configures a resizer binary built by Run.
+type Options struct {
+	// Name of the resizer, it is used as the leader election lock name, the tracing service name
+	// and the prefix of the default identity. Defaults to the name of the first backend.
+	Name string
+	// Master and KubeConfig locate the API server, the in-cluster config is used if both are empty.
+	Master     string
+	KubeConfig string
+	// Identity must be unique among replicas, defaults to Name followed by a random UUID.
+	Identity string
+	// ConfigFile is the path of the configuration file, which overrides other options and is reloaded
+	// once changed. See Configuration.
+	ConfigFile string
+
+	ResyncPeriod        time.Duration
+	Workers             int
+	ResizeTimeout       time.Duration
+	ShutdownGracePeriod time.Duration
+	Backoff             controller.BackoffConfig
+	DryRun              bool
+	EnableResizePolicy  bool
+	// LogFormat is one of logging.Formats.
+	LogFormat string
+
+	RateLimit controller.RateLimitConfig
+	// StorageClassResizeTimeouts override ResizeTimeout for PVCs of each StorageClass.
+	StorageClassResizeTimeouts map[string]time.Duration
+
+	LeaderElection LeaderElectionOptions
+	Metrics        MetricsOptions
+	Tracing        tracing.Config
+	Webhook        WebhookOptions
+	Autoscaler     AutoscalerOptions
+
+	// ControllerOptions are applied after options built from other fields, e.g. to add leader tasks.
+	ControllerOptions []controller.Option
+}
+
+// LeaderElectionOptions configures leader election, see util.LeaderElectionConfig.
+type LeaderElectionOptions struct {
+	Enabled       bool
+	Namespace     string
+	LockType      string
+	RetryPeriod   time.Duration
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	ReElect       bool
+}
+
+// MetricsOptions configures the metrics server, which is started only if Enabled is set.
+type MetricsOptions struct {
+	Enabled bool
+	controller.MetricConfig
+}
+
+// WebhookOptions configures the admission webhook server, which is started only if Enabled is set.
+type WebhookOptions struct {
+	Enabled bool
+	webhook.Config
+}
+
+// AutoscalerOptions configures the volume autoscaler, which runs only if Enabled is set.
+type AutoscalerOptions struct {
+	Enabled  bool
+	Interval time.Duration
+	// Source is AutoscalerSourceKubelet or AutoscalerSourcePrometheus.
+	Source        string
+	PrometheusURL string
+}
+
+// NewOptions creates Options with default values.
+func NewOptions() *Options {
+	return &Options{
+		ResyncPeriod:        2 * time.Minute,
+		Workers:             controller.DefaultWorkers,
+		ResizeTimeout:       2 * time.Minute,
+		ShutdownGracePeriod: 5 * time.Second,
+		Backoff:             controller.DefaultBackoffConfig,
+		LogFormat:           logging.FormatText,
+		RateLimit:           controller.DefaultRateLimitConfig,
+		LeaderElection: LeaderElectionOptions{
+			Namespace:     "kube-system",
+			LockType:      "endpoints",
+			RetryPeriod:   5 * time.Second,
+			LeaseDuration: 15 * time.Second,
+			RenewDeadline: 10 * time.Second,
+		},
+		Metrics: MetricsOptions{
+			MetricConfig: controller.MetricConfig{Path: "/metrics"},
+		},
+		Tracing: tracing.Config{
+			Exporter:    tracing.ExporterNone,
+			SampleRatio: 1,
+		},
+		Webhook: WebhookOptions{
+			Config: webhook.Config{Address: ":8443"},
+		},
+		Autoscaler: AutoscalerOptions{
+			Interval: time.Minute,
+			Source:   AutoscalerSourceKubelet,
+		},
+	}
+}
+
+// AddFlags registers the standard flags of resizers to fs, the current values of o are used as defaults.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Master, "master", o.Master, "Master URL")
+	fs.StringVar(&o.Identity, "identity", o.Identity, "Unique resizer identity")
+	fs.StringVar(&o.KubeConfig, "kubeconfig", o.KubeConfig, "Absolute path to the kubeconfig")
+	fs.StringVar(&o.ConfigFile, "config", o.ConfigFile,
+		"Path of the configuration file, which overrides flags and is reloaded once changed")
+	fs.DurationVar(&o.ResyncPeriod, "resync-period", o.ResyncPeriod, "Resync period for cache")
+	fs.IntVar(&o.Workers, "workers", o.Workers, "Concurrency to process multi resize requests")
+	fs.DurationVar(&o.ResizeTimeout, "resize-timeout", o.ResizeTimeout,
+		"Timeout of a single resize operation, 0 means no timeout")
+	fs.DurationVar(&o.Backoff.Base, "retry-interval-start", o.Backoff.Base,
+		"Initial retry interval of a failed resize operation, doubled on each failure")
+	fs.DurationVar(&o.Backoff.Max, "retry-interval-max", o.Backoff.Max,
+		"Max retry interval of a failed resize operation")
+	fs.Float64Var(&o.Backoff.Jitter, "retry-jitter", o.Backoff.Jitter,
+		"Max random jitter added to retry intervals, as a fraction of the interval")
+	fs.Float64Var(&o.RateLimit.QPS, "queue-qps", o.RateLimit.QPS,
+		"Max rate of resize operations of each backend, per second")
+	fs.IntVar(&o.RateLimit.Burst, "queue-burst", o.RateLimit.Burst,
+		"Max burst of resize operations of each backend")
+	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun,
+		"Only log and record events describing what would be done, without resizing volumes or updating PVs and PVCs")
+	fs.DurationVar(&o.ShutdownGracePeriod, "shutdown-grace-period", o.ShutdownGracePeriod,
+		"How long in-flight resize operations may keep running after the resizer stops or loses leadership. "+
+			"It should be shorter than the leader election lease duration.")
+
+	fs.BoolVar(&o.LeaderElection.Enabled, "leader-election", o.LeaderElection.Enabled, "Enable leader election.")
+	fs.StringVar(&o.LeaderElection.Namespace, "leader-election-namespace", o.LeaderElection.Namespace,
+		"Namespace where this resizer runs.")
+	fs.StringVar(&o.LeaderElection.LockType, "leader-election-lock-type", o.LeaderElection.LockType,
+		fmt.Sprintf("Type of the resource used as leader election lock, one of %v. "+
+			"To migrate existing deployments, roll out endpointsleases (or configmapsleases) first, then leases.",
+			util.LeaderElectionLockTypes))
+	fs.DurationVar(&o.LeaderElection.RetryPeriod, "leader-election-retry-period", o.LeaderElection.RetryPeriod,
+		"The duration the clients should wait between attempting acquisition and renewal "+
+			"of a leadership. This is only applicable if leader election is enabled.")
+	fs.DurationVar(&o.LeaderElection.LeaseDuration, "leader-election-lease-duration", o.LeaderElection.LeaseDuration,
+		"The duration that non-leader candidates will wait after observing a leadership "+
+			"renewal until attempting to acquire leadership of a led but unrenewed leader "+
+			"slot. This is effectively the maximum duration that a leader can be stopped "+
+			"before it is replaced by another candidate. This is only applicable if leader "+
+			"election is enabled.")
+	fs.DurationVar(&o.LeaderElection.RenewDeadline, "leader-election-renew-deadline", o.LeaderElection.RenewDeadline,
+		"The duration that the acting leader will retry refreshing leadership before giving up. "+
+			"This is only applicable if leader election is enabled.")
+	fs.BoolVar(&o.LeaderElection.ReElect, "leader-election-reelect", o.LeaderElection.ReElect,
+		"Re-enter leader election after leadership is lost instead of exiting.")
+
+	fs.BoolVar(&o.Metrics.Enabled, "enable-metrics", o.Metrics.Enabled, "Enable volume resize metrics")
+	fs.StringVar(&o.Metrics.Path, "metric-path", o.Metrics.Path, "Url path to access volume resize metrics")
+	fs.StringVar(&o.Metrics.Address, "metric-address", o.Metrics.Address, "Address the metric server listen on")
+	fs.StringVar(&o.Metrics.CertFile, "metric-cert-file", o.Metrics.CertFile,
+		"Serving certificate of the metric server, TLS is enabled if set")
+	fs.StringVar(&o.Metrics.KeyFile, "metric-key-file", o.Metrics.KeyFile,
+		"Serving key of the metric server, TLS is enabled if set")
+	fs.BoolVar(&o.Metrics.EnablePprof, "enable-pprof", o.Metrics.EnablePprof,
+		"Serve profiling data on /debug/pprof of the metric server")
+
+	fs.BoolVar(&o.EnableResizePolicy, "enable-resize-policy", o.EnableResizePolicy,
+		"Enforce ResizePolicies on resize requests, the ResizePolicy CRD must be installed")
+
+	fs.StringVar(&o.LogFormat, "log-format", o.LogFormat,
+		"Format of logs, text writes logs by glog, json writes one JSON object per line to stderr")
+
+	fs.StringVar(&o.Tracing.Exporter, "tracing-exporter", o.Tracing.Exporter,
+		"Where to export traces of resize operations, none, stdout or otlp")
+	fs.StringVar(&o.Tracing.OTLPEndpoint, "tracing-otlp-endpoint", o.Tracing.OTLPEndpoint,
+		"host:port of the OTLP HTTP trace receiver")
+	fs.BoolVar(&o.Tracing.OTLPInsecure, "tracing-otlp-insecure", o.Tracing.OTLPInsecure,
+		"Send traces to the OTLP receiver without TLS")
+	fs.Float64Var(&o.Tracing.SampleRatio, "tracing-sample-ratio", o.Tracing.SampleRatio,
+		"Ratio of resize operations traced")
+
+	fs.BoolVar(&o.Webhook.Enabled, "enable-webhook", o.Webhook.Enabled,
+		"Serve the admission webhook validating PVC resize requests")
+	fs.StringVar(&o.Webhook.Address, "webhook-address", o.Webhook.Address,
+		"Address the admission webhook server listens on")
+	fs.StringVar(&o.Webhook.CertFile, "webhook-cert-file", o.Webhook.CertFile,
+		"Serving certificate of the admission webhook server")
+	fs.StringVar(&o.Webhook.KeyFile, "webhook-key-file", o.Webhook.KeyFile,
+		"Serving key of the admission webhook server")
+	fs.Var((*stringSlice)(&o.Webhook.Hosts), "webhook-hosts",
+		"Comma separated hosts of the self-signed certificate, used if certificate and key files are not specified")
+
+	fs.BoolVar(&o.Autoscaler.Enabled, "enable-autoscaler", o.Autoscaler.Enabled,
+		"Expand PVCs automatically based on their volume usages")
+	fs.DurationVar(&o.Autoscaler.Interval, "autoscaler-interval", o.Autoscaler.Interval,
+		"Interval to check volume usages")
+	fs.StringVar(&o.Autoscaler.Source, "autoscaler-stats-source", o.Autoscaler.Source,
+		"Where to get volume usages from, kubelet or prometheus")
+	fs.StringVar(&o.Autoscaler.PrometheusURL, "autoscaler-prometheus-url", o.Autoscaler.PrometheusURL,
+		"Address of the Prometheus server, required if the stats source is prometheus")
+}
+
+// Validate checks if o is consistent, backend specific checks are left to Run.
+func (o *Options) Validate() error {
+	if o.Workers <= 0 {
+		return fmt.Errorf("workers must be positive")
+	}
+	if o.Metrics.Enabled && o.Metrics.Address == "" {
+		return fmt.Errorf("metric server address can't be empty")
+	}
+	if o.DryRun && o.Autoscaler.Enabled {
+		return fmt.Errorf("autoscaler can't be enabled in dry run mode")
+	}
+	if o.Autoscaler.Enabled {
+		switch o.Autoscaler.Source {
+		case AutoscalerSourceKubelet:
+		case AutoscalerSourcePrometheus:
+			if o.Autoscaler.PrometheusURL == "" {
+				return fmt.Errorf("prometheus url can't be empty")
+			}
+		default:
+			return fmt.Errorf("unknown autoscaler stats source %q", o.Autoscaler.Source)
+		}
+	}
+	return nil
+}
+
+// stringSlice is a flag.Value of comma separated strings.
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = nil
+	if value != "" {
+		*s = strings.Split(value, ",")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"time"
+
+	"github.com/mlmhl/external-resizer/controller"
+	"github.com/mlmhl/external-resizer/logging"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// configReloadInterval is how often the configuration file is checked for changes.
+// The file is polled rather than watched, since ConfigMaps are updated by swapping symlinks.
+const configReloadInterval = 10 * time.Second
+
+// configWatcher reloads the configuration file once changed and applies fields which are safe
+// to change at runtime, changes of other fields are logged and take effect after restart.
+type configWatcher struct {
+	path string
+	// base provides defaults of fields omitted in the file, i.e. options set by flags.
+	base *Options
+	rc   controller.ResizeController
+
+	// data and current are the content of the file and the configuration applied last time.
+	data    []byte
+	current *Configuration
+	logger  logr.Logger
+}
+
+func newConfigWatcher(path string, base *Options, current *Configuration, data []byte,
+	rc controller.ResizeController) *configWatcher {
+	return &configWatcher{
+		path:    path,
+		base:    base,
+		rc:      rc,
+		data:    data,
+		current: current,
+		logger:  logging.Logger().WithValues("config", path),
+	}
+}
+
+func (w *configWatcher) run(ctx context.Context) {
+	wait.Until(w.reload, configReloadInterval, ctx.Done())
+}
+
+func (w *configWatcher) reload() {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		w.logger.Error(err, "Failed to read configuration file")
+		return
+	}
+	if bytes.Equal(data, w.data) {
+		return
+	}
+	w.data = data
+
+	c, err := parseConfiguration(data, w.base)
+	if err != nil {
+		w.logger.Error(err, "Invalid configuration file, keep the current configuration")
+		return
+	}
+	w.logger.Info("Configuration file changed, applying")
+	if err := w.apply(c); err != nil {
+		w.logger.Error(err, "Failed to apply configuration")
+	}
+}
+
+// apply applies live fields of c and records them as current, other fields are left unchanged.
+func (w *configWatcher) apply(c *Configuration) error {
+	if c.Workers != w.current.Workers {
+		if err := w.rc.SetWorkers(c.Workers); err != nil {
+			return fmt.Errorf("failed to change workers: %v", err)
+		}
+		w.current.Workers = c.Workers
+	}
+	if c.Retry != w.current.Retry {
+		if err := w.rc.SetBackoff(c.backoff()); err != nil {
+			return fmt.Errorf("failed to change retry: %v", err)
+		}
+		w.current.Retry = c.Retry
+	}
+	if c.RateLimit != w.current.RateLimit {
+		if err := w.rc.SetRateLimit(c.rateLimit()); err != nil {
+			return fmt.Errorf("failed to change rate limit: %v", err)
+		}
+		w.current.RateLimit = c.RateLimit
+	}
+	if c.Logging.Verbosity != w.current.Logging.Verbosity {
+		if err := logging.SetVerbosity(c.Logging.Verbosity); err != nil {
+			return fmt.Errorf("failed to change log verbosity: %v", err)
+		}
+		w.logger.Info("Changed log verbosity", "verbosity", c.Logging.Verbosity)
+		w.current.Logging.Verbosity = c.Logging.Verbosity
+	}
+
+	if !reflect.DeepEqual(c, w.current) {
+		w.logger.Info("Some changes of the configuration file take effect after restart")
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mlmhl/external-resizer/controller"
+	"github.com/mlmhl/external-resizer/logging"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeResizeController records settings changed at runtime.
+type fakeResizeController struct {
+	workers   []int
+	backoff   []controller.BackoffConfig
+	rateLimit []controller.RateLimitConfig
+}
+
+func (c *fakeResizeController) Run(context.Context) error {
+	return nil
+}
+
+func (c *fakeResizeController) SetWorkers(workers int) error {
+	c.workers = append(c.workers, workers)
+	return nil
+}
+
+func (c *fakeResizeController) SetBackoff(config controller.BackoffConfig) error {
+	c.backoff = append(c.backoff, config)
+	return nil
+}
+
+func (c *fakeResizeController) SetRateLimit(config controller.RateLimitConfig) error {
+	c.rateLimit = append(c.rateLimit, config)
+	return nil
+}
+
+func (c *fakeResizeController) MetricsRegistry() *prometheus.Registry {
+	return prometheus.NewRegistry()
+}
+
+func TestConfigWatcherReload(t *testing.T) {
+	verbosity := logging.Verbosity()
+	defer logging.SetVerbosity(verbosity)
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	initial := `
+apiVersion: resizer.external-resizer.io/v1alpha1
+kind: ResizerConfiguration
+workers: 2
+`
+	writeConfig(initial)
+	base := NewOptions()
+	current, data, err := loadConfiguration(path, base)
+	if err != nil {
+		t.Fatalf("loadConfiguration failed: %v", err)
+	}
+	rc := &fakeResizeController{}
+	w := newConfigWatcher(path, base, current, data, rc)
+
+	// Nothing is applied if the file isn't changed.
+	w.reload()
+	if len(rc.workers) != 0 || len(rc.backoff) != 0 || len(rc.rateLimit) != 0 {
+		t.Fatalf("settings are changed without changing the file: %+v", rc)
+	}
+
+	writeConfig(`
+apiVersion: resizer.external-resizer.io/v1alpha1
+kind: ResizerConfiguration
+workers: 4
+resyncPeriod: 1h
+retry:
+  intervalStart: 2s
+  intervalMax: 1m
+rateLimit:
+  qps: 5
+  burst: 10
+logging:
+  verbosity: 7
+`)
+	w.reload()
+	if len(rc.workers) != 1 || rc.workers[0] != 4 {
+		t.Errorf("workers are changed to %v, want [4]", rc.workers)
+	}
+	wantBackoff := controller.BackoffConfig{Base: 2 * time.Second, Max: time.Minute, Jitter: base.Backoff.Jitter}
+	if len(rc.backoff) != 1 || rc.backoff[0] != wantBackoff {
+		t.Errorf("backoff is changed to %+v, want [%+v]", rc.backoff, wantBackoff)
+	}
+	wantRateLimit := controller.RateLimitConfig{QPS: 5, Burst: 10}
+	if len(rc.rateLimit) != 1 || rc.rateLimit[0] != wantRateLimit {
+		t.Errorf("rate limit is changed to %+v, want [%+v]", rc.rateLimit, wantRateLimit)
+	}
+	if v := logging.Verbosity(); v != 7 {
+		t.Errorf("verbosity = %d, want 7", v)
+	}
+	// Fields which take effect after restart are left unchanged.
+	if w.current.ResyncPeriod.Duration != base.ResyncPeriod {
+		t.Errorf("resyncPeriod = %v, want %v until restart", w.current.ResyncPeriod.Duration, base.ResyncPeriod)
+	}
+
+	// An invalid file keeps the current configuration.
+	writeConfig(`
+apiVersion: resizer.external-resizer.io/v1alpha1
+kind: ResizerConfiguration
+workers: 0
+`)
+	w.reload()
+	if len(rc.workers) != 1 || w.current.Workers != 4 {
+		t.Errorf("workers are changed to %v by an invalid file, current %d", rc.workers, w.current.Workers)
+	}
+}
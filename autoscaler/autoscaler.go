@@ -0,0 +1,144 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mlmhl/external-resizer/logging"
+	"github.com/mlmhl/external-resizer/util"
+
+	"github.com/go-logr/logr"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// Autoscaler expands PVCs automatically based on their volume usages. Autoscaling of a PVC is
+// driven by its annotations, see ThresholdAnnotation, IncreaseAnnotation and MaxSizeAnnotation.
+// Autoscaler only bumps the requested size of PVCs, the actual expansion is done by the resize controller.
+type Autoscaler struct {
+	kubeClient    kubernetes.Interface
+	pvcLister     corelisters.PersistentVolumeClaimLister
+	pvcSynced     cache.InformerSynced
+	source        StatsSource
+	interval      time.Duration
+	eventRecorder record.EventRecorder
+}
+
+// New creates an Autoscaler which checks volume usages from source every interval. PVCs are read from
+// pvcInformer, which is usually shared with the resize controller, see controller.WithInformerFactory.
+// The informer must be started by its owner.
+func New(kubeClient kubernetes.Interface, pvcInformer coreinformers.PersistentVolumeClaimInformer,
+	source StatsSource, interval time.Duration) *Autoscaler {
+	return &Autoscaler{
+		kubeClient: kubeClient,
+		pvcLister:  pvcInformer.Lister(),
+		pvcSynced:  pvcInformer.Informer().HasSynced,
+		source:     source,
+		interval:   interval,
+	}
+}
+
+// Run autoscales PVCs until ctx is done. Only one Autoscaler should run in a cluster,
+// e.g. run it as a leader task of the resize controller. Logs are written by the logger in ctx.
+func (a *Autoscaler) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx).WithName("autoscaler")
+	ctx = logging.NewContext(ctx, logger)
+	logger.Info("Starting volume autoscaler")
+	defer logger.Info("Shutting down volume autoscaler")
+
+	// The event broadcaster runs goroutines, so it's created by each run and shut down once the run ends.
+	// Run may be called again, e.g. once the leadership is regained, and a broadcaster can't be restarted.
+	eventBroadcaster := record.NewBroadcaster()
+	defer eventBroadcaster.Shutdown()
+	eventBroadcaster.StartLogging(func(format string, args ...interface{}) {
+		logger.Info(fmt.Sprintf(format, args...))
+	})
+	eventBroadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: a.kubeClient.CoreV1().Events(v1.NamespaceAll)})
+	a.eventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "external-resizer-autoscaler"})
+
+	if !cache.WaitForCacheSync(ctx.Done(), a.pvcSynced) {
+		return
+	}
+	wait.Until(func() { a.scale(ctx) }, a.interval, ctx.Done())
+}
+
+func (a *Autoscaler) scale(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	pvcs, err := a.pvcLister.List(labels.Everything())
+	if err != nil {
+		logger.Error(err, "List PVCs failed")
+		return
+	}
+
+	var usages map[string]VolumeUsage
+	for _, pvc := range pvcs {
+		if _, ok := pvc.Annotations[ThresholdAnnotation]; !ok {
+			continue
+		}
+		// Only fetch stats if there is any PVC to autoscale.
+		if usages == nil {
+			usages, err = a.source.VolumeUsages(ctx)
+			if err != nil {
+				logger.Error(err, "Get volume usages failed")
+				return
+			}
+		}
+		a.scalePVC(logger.WithValues(logging.KeyPVC, util.PVCKey(pvc)), pvc, usages)
+	}
+}
+
+func (a *Autoscaler) scalePVC(logger logr.Logger, pvc *v1.PersistentVolumeClaim, usages map[string]VolumeUsage) {
+	p, err := parsePolicy(pvc.Annotations)
+	if err != nil {
+		logger.Error(err, "Parse autoscaling policy failed")
+		a.eventRecorder.Event(pvc, v1.EventTypeWarning, util.VolumeAutoscaleFailed, err.Error())
+		return
+	}
+	if p == nil || pvc.Status.Phase != v1.ClaimBound {
+		return
+	}
+
+	actualSize, ok := pvc.Status.Capacity[v1.ResourceStorage]
+	if !ok {
+		return
+	}
+	requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if requestSize.Cmp(actualSize) != 0 {
+		logger.V(4).Info("PVC is being resized, skip autoscaling")
+		return
+	}
+
+	usage, ok := usages[util.PVCKey(pvc)]
+	if !ok {
+		logger.V(4).Info("No usage of PVC found, it may not be mounted by any pod")
+		return
+	}
+	if !p.exceeded(usage) {
+		return
+	}
+
+	newSize := p.nextSize(actualSize)
+	if newSize.Cmp(requestSize) <= 0 {
+		logger.V(4).Info("PVC already reaches its max size", "maxSize", p.maxSize.String())
+		return
+	}
+
+	if err := util.UpdatePVCRequestSize(pvc, newSize, a.kubeClient); err != nil {
+		logger.Error(err, "Autoscale PVC failed", logging.KeyRequestedSize, newSize.String())
+		return
+	}
+	logger.V(3).Info("Autoscaled PVC", "oldSize", actualSize.String(), logging.KeyRequestedSize, newSize.String(),
+		"usedBytes", usage.UsedBytes, "capacityBytes", usage.CapacityBytes)
+	a.eventRecorder.Event(pvc, v1.EventTypeNormal, util.VolumeAutoscaled,
+		fmt.Sprintf("Volume usage reaches %.0f%%, expand volume from %s to %s",
+			p.threshold, actualSize.String(), newSize.String()))
+}
@@ -0,0 +1,160 @@
+package autoscaler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeStatsSource returns fixed usages and counts how many times it is called.
+type fakeStatsSource struct {
+	usages map[string]VolumeUsage
+	calls  int
+}
+
+func (s *fakeStatsSource) VolumeUsages(context.Context) (map[string]VolumeUsage, error) {
+	s.calls++
+	return s.usages, nil
+}
+
+func newTestPVC(name string, size string, annotations map[string]string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+		Status: v1.PersistentVolumeClaimStatus{
+			Phase:    v1.ClaimBound,
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+		},
+	}
+}
+
+func TestScale(t *testing.T) {
+	policy := map[string]string{
+		ThresholdAnnotation: "80%",
+		IncreaseAnnotation:  "10Gi",
+		MaxSizeAnnotation:   "25Gi",
+	}
+	tests := []struct {
+		name     string
+		pvc      *v1.PersistentVolumeClaim
+		usage    *VolumeUsage
+		wantSize string
+	}{
+		{
+			name:     "below threshold",
+			pvc:      newTestPVC("pvc", "10Gi", policy),
+			usage:    &VolumeUsage{CapacityBytes: 100, UsedBytes: 79},
+			wantSize: "10Gi",
+		},
+		{
+			name:     "exceed threshold",
+			pvc:      newTestPVC("pvc", "10Gi", policy),
+			usage:    &VolumeUsage{CapacityBytes: 100, UsedBytes: 80},
+			wantSize: "20Gi",
+		},
+		{
+			name:     "capped by max size",
+			pvc:      newTestPVC("pvc", "20Gi", policy),
+			usage:    &VolumeUsage{CapacityBytes: 100, UsedBytes: 90},
+			wantSize: "25Gi",
+		},
+		{
+			name:     "no usage",
+			pvc:      newTestPVC("pvc", "10Gi", policy),
+			wantSize: "10Gi",
+		},
+		{
+			name:     "autoscaling disabled",
+			pvc:      newTestPVC("pvc", "10Gi", nil),
+			usage:    &VolumeUsage{CapacityBytes: 100, UsedBytes: 100},
+			wantSize: "10Gi",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset(test.pvc)
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+			source := &fakeStatsSource{usages: map[string]VolumeUsage{}}
+			if test.usage != nil {
+				source.usages["default/"+test.pvc.Name] = *test.usage
+			}
+			a := New(kubeClient, informerFactory.Core().V1().PersistentVolumeClaims(), source, time.Minute)
+			a.eventRecorder = record.NewFakeRecorder(10)
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			informerFactory.Start(stopCh)
+			informerFactory.WaitForCacheSync(stopCh)
+			kubeClient.ClearActions()
+			a.scale(context.Background())
+
+			// PVCs are read from the informer cache rather than listed from the API server.
+			for _, action := range kubeClient.Actions() {
+				if action.GetVerb() == "list" {
+					t.Errorf("unexpected list of %s", action.GetResource().Resource)
+				}
+			}
+			if test.pvc.Annotations == nil && source.calls != 0 {
+				t.Errorf("stats source is called %d times without PVCs to autoscale", source.calls)
+			}
+
+			pvc, err := kubeClient.CoreV1().PersistentVolumeClaims("default").Get(test.pvc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("get PVC failed: %v", err)
+			}
+			size := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+			if want := resource.MustParse(test.wantSize); size.Cmp(want) != 0 {
+				t.Errorf("request size = %s, want %s", size.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestRunAgain(t *testing.T) {
+	// An invalid policy is reported by an event each time the PVC is checked.
+	pvc := newTestPVC("pvc", "10Gi", map[string]string{ThresholdAnnotation: "invalid"})
+	kubeClient := fake.NewSimpleClientset(pvc)
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	a := New(kubeClient, informerFactory.Core().V1().PersistentVolumeClaims(), &fakeStatsSource{}, time.Minute)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+
+	// Events are still recorded after a run ends and another starts, e.g. once the leadership is regained.
+	for i := 1; i <= 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			a.Run(ctx)
+		}()
+		err := wait.PollImmediate(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+			created := 0
+			for _, action := range kubeClient.Actions() {
+				if action.GetVerb() == "create" && action.GetResource().Resource == "events" {
+					created++
+				}
+			}
+			return created >= i, nil
+		})
+		cancel()
+		<-done
+		if err != nil {
+			t.Fatalf("run %d: no event is recorded: %v", i, err)
+		}
+	}
+}
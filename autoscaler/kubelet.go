@@ -0,0 +1,79 @@
+package autoscaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mlmhl/external-resizer/logging"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewKubeletStatsSource returns a StatsSource which reads kubelet /stats/summary of all nodes
+// through the API server's node proxy.
+func NewKubeletStatsSource(kubeClient kubernetes.Interface) StatsSource {
+	return &kubeletStatsSource{kubeClient: kubeClient}
+}
+
+type kubeletStatsSource struct {
+	kubeClient kubernetes.Interface
+}
+
+// summary contains the fields we need of kubelet's stats summary.
+type summary struct {
+	Pods []struct {
+		Volumes []struct {
+			CapacityBytes *int64 `json:"capacityBytes"`
+			UsedBytes     *int64 `json:"usedBytes"`
+			PVCRef        *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+func (s *kubeletStatsSource) VolumeUsages(ctx context.Context) (map[string]VolumeUsage, error) {
+	nodes, err := s.kubeClient.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list nodes failed: %v", err)
+	}
+
+	usages := make(map[string]VolumeUsage)
+	for _, node := range nodes.Items {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		data, err := s.kubeClient.CoreV1().RESTClient().Get().
+			Context(ctx).
+			Resource("nodes").
+			Name(node.Name).
+			SubResource("proxy").
+			Suffix("stats/summary").
+			DoRaw()
+		if err != nil {
+			// Don't let one unhealthy node block autoscaling of volumes on other nodes.
+			logging.FromContext(ctx).Error(err, "Get stats summary of node failed", "node", node.Name)
+			continue
+		}
+		var nodeSummary summary
+		if err := json.Unmarshal(data, &nodeSummary); err != nil {
+			logging.FromContext(ctx).Error(err, "Decode stats summary of node failed", "node", node.Name)
+			continue
+		}
+		for _, pod := range nodeSummary.Pods {
+			for _, volume := range pod.Volumes {
+				if volume.PVCRef == nil || volume.CapacityBytes == nil || volume.UsedBytes == nil {
+					continue
+				}
+				usages[volume.PVCRef.Namespace+"/"+volume.PVCRef.Name] = VolumeUsage{
+					CapacityBytes: *volume.CapacityBytes,
+					UsedBytes:     *volume.UsedBytes,
+				}
+			}
+		}
+	}
+	return usages, nil
+}
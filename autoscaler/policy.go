@@ -0,0 +1,122 @@
+package autoscaler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// ThresholdAnnotation enables autoscaling of the PVC, the volume is expanded once its usage
+	// reaches this percentage of its capacity, e.g. "80%".
+	ThresholdAnnotation = util.ParameterPrefix + "autoscale-threshold"
+	// IncreaseAnnotation is how much to expand the volume each time, either a size like "10Gi" or
+	// a percentage of the current capacity like "20%". Defaults to DefaultIncrease.
+	IncreaseAnnotation = util.ParameterPrefix + "autoscale-increase"
+	// MaxSizeAnnotation is the size the volume will never be expanded beyond, e.g. "100Gi". It is required.
+	MaxSizeAnnotation = util.ParameterPrefix + "autoscale-max-size"
+
+	DefaultIncrease = "20%"
+)
+
+// Sizes are rounded up to multiples of this so that percentages don't produce odd sizes.
+var sizeUnit = resource.MustParse("1Mi")
+
+// policy describes how to autoscale a PVC.
+type policy struct {
+	// threshold is the percentage of usage which triggers an expansion.
+	threshold float64
+	// Either increase or increasePercent is set.
+	increase        *resource.Quantity
+	increasePercent float64
+	maxSize         resource.Quantity
+}
+
+// parsePolicy parses the autoscaling policy from PVC annotations, nil is returned if autoscaling is disabled.
+func parsePolicy(annotations map[string]string) (*policy, error) {
+	thresholdStr, ok := annotations[ThresholdAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	p := &policy{}
+
+	var err error
+	p.threshold, err = parsePercentage(thresholdStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ThresholdAnnotation, err)
+	}
+	if p.threshold <= 0 || p.threshold > 100 {
+		return nil, fmt.Errorf("invalid %s: must be in (0%%, 100%%]", ThresholdAnnotation)
+	}
+
+	increaseStr, ok := annotations[IncreaseAnnotation]
+	if !ok {
+		increaseStr = DefaultIncrease
+	}
+	if strings.HasSuffix(increaseStr, "%") {
+		p.increasePercent, err = parsePercentage(increaseStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", IncreaseAnnotation, err)
+		}
+		if p.increasePercent <= 0 {
+			return nil, fmt.Errorf("invalid %s: must be positive", IncreaseAnnotation)
+		}
+	} else {
+		increase, err := resource.ParseQuantity(increaseStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", IncreaseAnnotation, err)
+		}
+		if increase.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid %s: must be positive", IncreaseAnnotation)
+		}
+		p.increase = &increase
+	}
+
+	maxSizeStr, ok := annotations[MaxSizeAnnotation]
+	if !ok {
+		return nil, fmt.Errorf("%s is required", MaxSizeAnnotation)
+	}
+	p.maxSize, err = resource.ParseQuantity(maxSizeStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", MaxSizeAnnotation, err)
+	}
+
+	return p, nil
+}
+
+func parsePercentage(str string) (float64, error) {
+	if !strings.HasSuffix(str, "%") {
+		return 0, fmt.Errorf("%q is not a percentage", str)
+	}
+	return strconv.ParseFloat(strings.TrimSuffix(str, "%"), 64)
+}
+
+// exceeded returns true if the usage reaches the threshold.
+func (p *policy) exceeded(usage VolumeUsage) bool {
+	if usage.CapacityBytes <= 0 {
+		return false
+	}
+	return float64(usage.UsedBytes)*100 >= float64(usage.CapacityBytes)*p.threshold
+}
+
+// nextSize returns the size to expand a volume of current size to, capped by the max size.
+func (p *policy) nextSize(current resource.Quantity) resource.Quantity {
+	var newBytes int64
+	if p.increase != nil {
+		newBytes = current.Value() + p.increase.Value()
+	} else {
+		newBytes = current.Value() + int64(float64(current.Value())*p.increasePercent/100)
+	}
+	unit := sizeUnit.Value()
+	newBytes = (newBytes + unit - 1) / unit * unit
+
+	newSize := *resource.NewQuantity(newBytes, resource.BinarySI)
+	if newSize.Cmp(p.maxSize) > 0 {
+		return p.maxSize
+	}
+	return newSize
+}
@@ -0,0 +1,105 @@
+package autoscaler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	capacityBytesMetric = "kubelet_volume_stats_capacity_bytes"
+	usedBytesMetric     = "kubelet_volume_stats_used_bytes"
+)
+
+// NewPrometheusStatsSource returns a StatsSource which queries kubelet volume stats metrics
+// from the Prometheus HTTP API at address, e.g. http://prometheus.monitoring:9090.
+func NewPrometheusStatsSource(address string, client *http.Client) StatsSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &prometheusStatsSource{address: strings.TrimSuffix(address, "/"), client: client}
+}
+
+type prometheusStatsSource struct {
+	address string
+	client  *http.Client
+}
+
+// queryResponse contains the fields we need of an instant query response.
+type queryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Metric map[string]string `json:"metric"`
+			// Value is a [timestamp, "value"] pair.
+			Value []interface{} `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func (s *prometheusStatsSource) VolumeUsages(ctx context.Context) (map[string]VolumeUsage, error) {
+	capacities, err := s.query(ctx, capacityBytesMetric)
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.query(ctx, usedBytesMetric)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]VolumeUsage, len(capacities))
+	for key, capacity := range capacities {
+		usedBytes, ok := used[key]
+		if !ok {
+			continue
+		}
+		usages[key] = VolumeUsage{CapacityBytes: capacity, UsedBytes: usedBytes}
+	}
+	return usages, nil
+}
+
+// query returns values of the metric keyed by PVC's namespace/name.
+func (s *prometheusStatsSource) query(ctx context.Context, metric string) (map[string]int64, error) {
+	// A PVC may be reported by multiple kubelets during migration, take the max value.
+	query := fmt.Sprintf("max by (namespace, persistentvolumeclaim) (%s)", metric)
+	req, err := http.NewRequest(http.MethodGet, s.address+"/api/v1/query?query="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("query %s failed: %v", metric, err)
+	}
+	defer rsp.Body.Close()
+
+	var result queryResponse
+	if err := json.NewDecoder(rsp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response of query %s failed: %v", metric, err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("query %s failed: %s", metric, result.Error)
+	}
+
+	values := make(map[string]int64, len(result.Data.Result))
+	for _, sample := range result.Data.Result {
+		namespace, name := sample.Metric["namespace"], sample.Metric["persistentvolumeclaim"]
+		if namespace == "" || name == "" || len(sample.Value) != 2 {
+			continue
+		}
+		str, ok := sample.Value[1].(string)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			continue
+		}
+		values[namespace+"/"+name] = int64(value)
+	}
+	return values, nil
+}
@@ -0,0 +1,15 @@
+package autoscaler
+
+import "context"
+
+// VolumeUsage is the usage of the file system on a volume.
+type VolumeUsage struct {
+	CapacityBytes int64
+	UsedBytes     int64
+}
+
+// StatsSource provides volume usages of PVCs.
+type StatsSource interface {
+	// VolumeUsages returns usages of all PVCs it knows, keyed by PVC's namespace/name.
+	VolumeUsages(ctx context.Context) (map[string]VolumeUsage, error)
+}
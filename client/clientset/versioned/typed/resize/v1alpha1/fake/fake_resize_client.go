@@ -0,0 +1,24 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/mlmhl/external-resizer/client/clientset/versioned/typed/resize/v1alpha1"
+	rest "k8s.io/client-go/rest"
+	testing "k8s.io/client-go/testing"
+)
+
+type FakeResizeV1alpha1 struct {
+	*testing.Fake
+}
+
+func (c *FakeResizeV1alpha1) ResizePolicies(namespace string) v1alpha1.ResizePolicyInterface {
+	return &FakeResizePolicies{c, namespace}
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *FakeResizeV1alpha1) RESTClient() rest.Interface {
+	var ret *rest.RESTClient
+	return ret
+}
@@ -0,0 +1,112 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/mlmhl/external-resizer/apis/resize/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeResizePolicies implements ResizePolicyInterface
+type FakeResizePolicies struct {
+	Fake *FakeResizeV1alpha1
+	ns   string
+}
+
+var resizepoliciesResource = schema.GroupVersionResource{Group: "resize.external-resizer.io", Version: "v1alpha1", Resource: "resizepolicies"}
+
+var resizepoliciesKind = schema.GroupVersionKind{Group: "resize.external-resizer.io", Version: "v1alpha1", Kind: "ResizePolicy"}
+
+// Get takes name of the resizePolicy, and returns the corresponding resizePolicy object, and an error if there is any.
+func (c *FakeResizePolicies) Get(name string, options v1.GetOptions) (result *v1alpha1.ResizePolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(resizepoliciesResource, c.ns, name), &v1alpha1.ResizePolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ResizePolicy), err
+}
+
+// List takes label and field selectors, and returns the list of ResizePolicies that match those selectors.
+func (c *FakeResizePolicies) List(opts v1.ListOptions) (result *v1alpha1.ResizePolicyList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(resizepoliciesResource, resizepoliciesKind, c.ns, opts), &v1alpha1.ResizePolicyList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.ResizePolicyList{ListMeta: obj.(*v1alpha1.ResizePolicyList).ListMeta}
+	for _, item := range obj.(*v1alpha1.ResizePolicyList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested resizePolicies.
+func (c *FakeResizePolicies) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(resizepoliciesResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a resizePolicy and creates it.  Returns the server's representation of the resizePolicy, and an error, if there is any.
+func (c *FakeResizePolicies) Create(resizePolicy *v1alpha1.ResizePolicy) (result *v1alpha1.ResizePolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(resizepoliciesResource, c.ns, resizePolicy), &v1alpha1.ResizePolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ResizePolicy), err
+}
+
+// Update takes the representation of a resizePolicy and updates it. Returns the server's representation of the resizePolicy, and an error, if there is any.
+func (c *FakeResizePolicies) Update(resizePolicy *v1alpha1.ResizePolicy) (result *v1alpha1.ResizePolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(resizepoliciesResource, c.ns, resizePolicy), &v1alpha1.ResizePolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ResizePolicy), err
+}
+
+// Delete takes name of the resizePolicy and deletes it. Returns an error if one occurs.
+func (c *FakeResizePolicies) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(resizepoliciesResource, c.ns, name), &v1alpha1.ResizePolicy{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeResizePolicies) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(resizepoliciesResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.ResizePolicyList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched resizePolicy.
+func (c *FakeResizePolicies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.ResizePolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(resizepoliciesResource, c.ns, name, pt, data, subresources...), &v1alpha1.ResizePolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.ResizePolicy), err
+}
@@ -0,0 +1,158 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"time"
+
+	v1alpha1 "github.com/mlmhl/external-resizer/apis/resize/v1alpha1"
+	scheme "github.com/mlmhl/external-resizer/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ResizePoliciesGetter has a method to return a ResizePolicyInterface.
+// A group's client should implement this interface.
+type ResizePoliciesGetter interface {
+	ResizePolicies(namespace string) ResizePolicyInterface
+}
+
+// ResizePolicyInterface has methods to work with ResizePolicy resources.
+type ResizePolicyInterface interface {
+	Create(*v1alpha1.ResizePolicy) (*v1alpha1.ResizePolicy, error)
+	Update(*v1alpha1.ResizePolicy) (*v1alpha1.ResizePolicy, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.ResizePolicy, error)
+	List(opts v1.ListOptions) (*v1alpha1.ResizePolicyList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.ResizePolicy, err error)
+	ResizePolicyExpansion
+}
+
+// resizePolicies implements ResizePolicyInterface
+type resizePolicies struct {
+	client rest.Interface
+	ns     string
+}
+
+// newResizePolicies returns a ResizePolicies
+func newResizePolicies(c *ResizeV1alpha1Client, namespace string) *resizePolicies {
+	return &resizePolicies{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the resizePolicy, and returns the corresponding resizePolicy object, and an error if there is any.
+func (c *resizePolicies) Get(name string, options v1.GetOptions) (result *v1alpha1.ResizePolicy, err error) {
+	result = &v1alpha1.ResizePolicy{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("resizepolicies").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ResizePolicies that match those selectors.
+func (c *resizePolicies) List(opts v1.ListOptions) (result *v1alpha1.ResizePolicyList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.ResizePolicyList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("resizepolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested resizePolicies.
+func (c *resizePolicies) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("resizepolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a resizePolicy and creates it.  Returns the server's representation of the resizePolicy, and an error, if there is any.
+func (c *resizePolicies) Create(resizePolicy *v1alpha1.ResizePolicy) (result *v1alpha1.ResizePolicy, err error) {
+	result = &v1alpha1.ResizePolicy{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("resizepolicies").
+		Body(resizePolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a resizePolicy and updates it. Returns the server's representation of the resizePolicy, and an error, if there is any.
+func (c *resizePolicies) Update(resizePolicy *v1alpha1.ResizePolicy) (result *v1alpha1.ResizePolicy, err error) {
+	result = &v1alpha1.ResizePolicy{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("resizepolicies").
+		Name(resizePolicy.Name).
+		Body(resizePolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the resizePolicy and deletes it. Returns an error if one occurs.
+func (c *resizePolicies) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("resizepolicies").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *resizePolicies) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("resizepolicies").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched resizePolicy.
+func (c *resizePolicies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.ResizePolicy, err error) {
+	result = &v1alpha1.ResizePolicy{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("resizepolicies").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
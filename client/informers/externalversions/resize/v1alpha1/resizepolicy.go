@@ -0,0 +1,73 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	time "time"
+
+	resizev1alpha1 "github.com/mlmhl/external-resizer/apis/resize/v1alpha1"
+	versioned "github.com/mlmhl/external-resizer/client/clientset/versioned"
+	internalinterfaces "github.com/mlmhl/external-resizer/client/informers/externalversions/internalinterfaces"
+	v1alpha1 "github.com/mlmhl/external-resizer/client/listers/resize/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// ResizePolicyInformer provides access to a shared informer and lister for
+// ResizePolicies.
+type ResizePolicyInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1alpha1.ResizePolicyLister
+}
+
+type resizePolicyInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewResizePolicyInformer constructs a new informer for ResizePolicy type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewResizePolicyInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredResizePolicyInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredResizePolicyInformer constructs a new informer for ResizePolicy type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredResizePolicyInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.ResizeV1alpha1().ResizePolicies(namespace).List(options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.ResizeV1alpha1().ResizePolicies(namespace).Watch(options)
+			},
+		},
+		&resizev1alpha1.ResizePolicy{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *resizePolicyInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredResizePolicyInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *resizePolicyInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&resizev1alpha1.ResizePolicy{}, f.defaultInformer)
+}
+
+func (f *resizePolicyInformer) Lister() v1alpha1.ResizePolicyLister {
+	return v1alpha1.NewResizePolicyLister(f.Informer().GetIndexer())
+}
@@ -0,0 +1,78 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/mlmhl/external-resizer/apis/resize/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ResizePolicyLister helps list ResizePolicies.
+type ResizePolicyLister interface {
+	// List lists all ResizePolicies in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.ResizePolicy, err error)
+	// ResizePolicies returns an object that can list and get ResizePolicies.
+	ResizePolicies(namespace string) ResizePolicyNamespaceLister
+	ResizePolicyListerExpansion
+}
+
+// resizePolicyLister implements the ResizePolicyLister interface.
+type resizePolicyLister struct {
+	indexer cache.Indexer
+}
+
+// NewResizePolicyLister returns a new ResizePolicyLister.
+func NewResizePolicyLister(indexer cache.Indexer) ResizePolicyLister {
+	return &resizePolicyLister{indexer: indexer}
+}
+
+// List lists all ResizePolicies in the indexer.
+func (s *resizePolicyLister) List(selector labels.Selector) (ret []*v1alpha1.ResizePolicy, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.ResizePolicy))
+	})
+	return ret, err
+}
+
+// ResizePolicies returns an object that can list and get ResizePolicies.
+func (s *resizePolicyLister) ResizePolicies(namespace string) ResizePolicyNamespaceLister {
+	return resizePolicyNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// ResizePolicyNamespaceLister helps list and get ResizePolicies.
+type ResizePolicyNamespaceLister interface {
+	// List lists all ResizePolicies in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1alpha1.ResizePolicy, err error)
+	// Get retrieves the ResizePolicy from the indexer for a given namespace and name.
+	Get(name string) (*v1alpha1.ResizePolicy, error)
+	ResizePolicyNamespaceListerExpansion
+}
+
+// resizePolicyNamespaceLister implements the ResizePolicyNamespaceLister
+// interface.
+type resizePolicyNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all ResizePolicies in the indexer for a given namespace.
+func (s resizePolicyNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.ResizePolicy, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.ResizePolicy))
+	})
+	return ret, err
+}
+
+// Get retrieves the ResizePolicy from the indexer for a given namespace and name.
+func (s resizePolicyNamespaceLister) Get(name string) (*v1alpha1.ResizePolicy, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("resizepolicy"), name)
+	}
+	return obj.(*v1alpha1.ResizePolicy), nil
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Backend is a named resizer hosted by the resize controller.
+type Backend struct {
+	// Name identifies the backend, it is used to label metrics and events of the backend.
+	Name    string
+	Resizer ContextResizer
+
+	// DriverName routes CSI volumes of this driver to the backend, optional.
+	DriverName string
+	// Provisioners routes volumes whose StorageClass is provisioned by one of them to the backend, optional.
+	Provisioners []string
+}
+
+// Registry holds backends of the resize controller. A PV is routed to the first backend
+// whose DriverName matches the CSI driver of the PV, then to the first backend whose Provisioners
+// contains the provisioner of the PV's StorageClass, and finally to the first backend whose
+// Resizer supports the PV. Backends are checked in the order of registration.
+type Registry struct {
+	backends []Backend
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a backend to the registry, backend names must be unique.
+func (r *Registry) Register(backend Backend) error {
+	if backend.Name == "" {
+		return fmt.Errorf("backend name can't be empty")
+	}
+	if backend.Resizer == nil {
+		return fmt.Errorf("resizer of backend %s can't be nil", backend.Name)
+	}
+	for _, b := range r.backends {
+		if b.Name == backend.Name {
+			return fmt.Errorf("backend %s already registered", backend.Name)
+		}
+	}
+	r.backends = append(r.backends, backend)
+	return nil
+}
+
+// Backends returns registered backends in the order of registration.
+func (r *Registry) Backends() []Backend {
+	return append([]Backend(nil), r.backends...)
+}
+
+// backend holds the runtime state of a registered Backend.
+type backend struct {
+	Backend
+
+	capabilities  Capabilities
+	eventRecorder record.EventRecorder
+	// failureEvents deduplicates resize failure events of PVCs.
+	failureEvents *eventFilter
+	// dryRunEvents deduplicates events of PVCs in dry run mode, in which no condition is written to PVCs.
+	dryRunEvents *eventFilter
+
+	// backoff and bucket are shared by queues of the backend, so failures are kept across queue renewals.
+	backoff *backoffRateLimiter
+	bucket  *bucketRateLimiter
+
+	// queueMetrics records metrics of queues of the backend, they are metrics of the controller.
+	queueMetrics *workqueueMetrics
+
+	queueName string
+	// claimQueue is shut down when the controller stops running and renewed when it runs again,
+	// so it must be accessed by queue().
+	queueLock  sync.RWMutex
+	claimQueue workqueue.RateLimitingInterface
+}
+
+// queue returns the current work queue of the backend.
+func (b *backend) queue() workqueue.RateLimitingInterface {
+	b.queueLock.RLock()
+	defer b.queueLock.RUnlock()
+	return b.claimQueue
+}
+
+// renewQueue replaces the queue if it is shut down.
+func (b *backend) renewQueue() {
+	b.queueLock.Lock()
+	defer b.queueLock.Unlock()
+	if b.claimQueue == nil || b.claimQueue.ShuttingDown() {
+		// The bucket is not part of the queue's rate limiter, it's applied by workers before resizing,
+		// so that it limits first attempts as well as retries.
+		b.claimQueue = newNamedRateLimitingQueue(b.queueMetrics, b.backoff, b.queueName)
+	}
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// BackoffConfig configures how failed resize operations of a PVC are retried.
+// The n-th retry happens after Base * 2^(n-1), at most Max, plus a random jitter of
+// at most Jitter times the delay.
+type BackoffConfig struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+// DefaultBackoffConfig is used if WithBackoff isn't specified.
+var DefaultBackoffConfig = BackoffConfig{
+	Base:   time.Second,
+	Max:    5 * time.Minute,
+	Jitter: 0.1,
+}
+
+func (c BackoffConfig) validate() error {
+	if c.Base <= 0 {
+		return fmt.Errorf("backoff base must be positive")
+	}
+	if c.Max < c.Base {
+		return fmt.Errorf("max backoff %v must not be smaller than base %v", c.Max, c.Base)
+	}
+	if c.Jitter < 0 {
+		return fmt.Errorf("backoff jitter must not be negative")
+	}
+	return nil
+}
+
+// backoffRateLimiter is a workqueue.RateLimiter implementing per item exponential backoff with jitter.
+type backoffRateLimiter struct {
+	config BackoffConfig
+
+	lock     sync.Mutex
+	failures map[interface{}]int
+}
+
+func newBackoffRateLimiter(config BackoffConfig) *backoffRateLimiter {
+	return &backoffRateLimiter{
+		config:   config,
+		failures: make(map[interface{}]int),
+	}
+}
+
+// setConfig changes the backoff of following failures, failure counts are kept.
+func (r *backoffRateLimiter) setConfig(config BackoffConfig) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.config = config
+}
+
+func (r *backoffRateLimiter) When(item interface{}) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	exp := r.failures[item]
+	r.failures[item]++
+
+	delay := float64(r.config.Base) * math.Pow(2, float64(exp))
+	if delay > float64(r.config.Max) {
+		delay = float64(r.config.Max)
+	}
+	if r.config.Jitter > 0 {
+		delay += rand.Float64() * r.config.Jitter * delay
+	}
+	return time.Duration(delay)
+}
+
+func (r *backoffRateLimiter) NumRequeues(item interface{}) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.failures[item]
+}
+
+func (r *backoffRateLimiter) Forget(item interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.failures, item)
+}
+
+// RateLimitConfig limits the overall rate of resize operations of a backend, retries included,
+// in addition to the backoff of each PVC. PVCs needing no resize are processed without limit.
+type RateLimitConfig struct {
+	QPS   float64
+	Burst int
+}
+
+// DefaultRateLimitConfig is used if WithRateLimit isn't specified, the same limit as workqueue.DefaultControllerRateLimiter.
+var DefaultRateLimitConfig = RateLimitConfig{
+	QPS:   10,
+	Burst: 100,
+}
+
+func (c RateLimitConfig) validate() error {
+	if c.QPS <= 0 {
+		return fmt.Errorf("rate limit qps must be positive")
+	}
+	if c.Burst <= 0 {
+		return fmt.Errorf("rate limit burst must be positive")
+	}
+	return nil
+}
+
+// bucketRateLimiter is a token bucket limiting resize operations of a backend, whose limit can be changed.
+type bucketRateLimiter struct {
+	lock    sync.RWMutex
+	limiter *rate.Limiter
+}
+
+func newBucketRateLimiter(config RateLimitConfig) *bucketRateLimiter {
+	return &bucketRateLimiter{limiter: rate.NewLimiter(rate.Limit(config.QPS), config.Burst)}
+}
+
+// setConfig replaces the token bucket, so tokens taken before are not counted by the new limit.
+func (r *bucketRateLimiter) setConfig(config RateLimitConfig) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.limiter = rate.NewLimiter(rate.Limit(config.QPS), config.Burst)
+}
+
+// wait blocks until a resize operation is allowed, an error is returned if ctx is done before.
+func (r *bucketRateLimiter) wait(ctx context.Context) error {
+	r.lock.RLock()
+	limiter := r.limiter
+	r.lock.RUnlock()
+	return limiter.Wait(ctx)
+}
+
+// retryAfterError is returned by resizeFunc if the resize failed and the PVC should be retried
+// after the delay, which is already taken from the backoff rate limiter and recorded on the PVC.
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// failureEventInterval is the min interval between two identical failure events of a PVC.
+const failureEventInterval = time.Hour
+
+// eventFilter suppresses repeated failure events, so that a flapping backend doesn't flood namespaces.
+type eventFilter struct {
+	lock     sync.Mutex
+	recorded map[string]recordedEvent
+}
+
+type recordedEvent struct {
+	message string
+	time    time.Time
+}
+
+func newEventFilter() *eventFilter {
+	return &eventFilter{recorded: make(map[string]recordedEvent)}
+}
+
+// shouldRecord returns false if the same message was recorded for key within failureEventInterval.
+func (f *eventFilter) shouldRecord(key, message string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	now := time.Now()
+	if last, ok := f.recorded[key]; ok && last.message == message && now.Sub(last.time) < failureEventInterval {
+		return false
+	}
+	f.recorded[key] = recordedEvent{message: message, time: now}
+	return true
+}
+
+func (f *eventFilter) forget(key string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.recorded, key)
+}
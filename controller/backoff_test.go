@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBucketRateLimiterWait(t *testing.T) {
+	limiter := newBucketRateLimiter(RateLimitConfig{QPS: 1000, Burst: 1})
+	limiter.setConfig(RateLimitConfig{QPS: 10, Burst: 2})
+
+	startTime := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.wait(context.Background()); err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+	}
+	// The burst is taken immediately, the other two operations wait 100ms each.
+	if elapsed := time.Since(startTime); elapsed < 150*time.Millisecond {
+		t.Errorf("4 operations finished in %v with qps 10 and burst 2, want at least 150ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.wait(ctx); err == nil {
+		t.Errorf("wait succeeded after the context is cancelled")
+	}
+}
@@ -3,78 +3,226 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/mlmhl/external-resizer/client/clientset/versioned"
+	resizeinformers "github.com/mlmhl/external-resizer/client/informers/externalversions"
+	resizelisters "github.com/mlmhl/external-resizer/client/listers/resize/v1alpha1"
+	"github.com/mlmhl/external-resizer/logging"
 	"github.com/mlmhl/external-resizer/util"
 
-	"github.com/golang/glog"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/client-go/util/workqueue"
 )
 
+// DefaultWorkers is the number of workers of each backend if WithWorkers isn't specified.
+const DefaultWorkers = 10
+
 type ResizeController interface {
-	Run(workers int, stopCh <-chan struct{}, metricConfig *MetricConfig, leaderElectionConfig *util.LeaderElectionConfig)
+	// Run runs the controller until ctx is done, it is configured by options of NewResizeController.
+	// nil is returned once ctx is done. An error is returned if the controller fails to start or stops
+	// unexpectedly, e.g. caches can't be synced, the metrics server fails, or leadership is lost while
+	// re-election is disabled, in which case util.ErrLeadershipLost is returned.
+	Run(ctx context.Context) error
+
+	// SetWorkers changes the number of workers of each backend, it takes effect immediately if the controller
+	// is running. Workers above the new number exit once they finish their current PVC.
+	SetWorkers(workers int) error
+	// SetBackoff changes how failed resize operations are retried, it applies to following failures.
+	SetBackoff(config BackoffConfig) error
+	// SetRateLimit changes the overall rate limit of each backend.
+	SetRateLimit(config RateLimitConfig) error
+
+	// MetricsRegistry returns the registry metrics of the controller are registered on, e.g. to serve them
+	// by the embedding application rather than by the metrics server, see WithMetrics.
+	MetricsRegistry() *prometheus.Registry
 }
 
-type resizeFunc func(pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) error
+type resizeFunc func(ctx context.Context, b *backend, pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) error
 
 type resizeController struct {
-	identity        string
-	resizer         Resizer
-	kubeClient      kubernetes.Interface
-	claimQueue      workqueue.RateLimitingInterface
-	eventRecorder   record.EventRecorder
-	pvLister        corelisters.PersistentVolumeLister
-	pvSynced        cache.InformerSynced
-	pvcLister       corelisters.PersistentVolumeClaimLister
-	pvcSynced       cache.InformerSynced
-	informerFactory informers.SharedInformerFactory
+	identity      string
+	backends      []*backend
+	threadiness   int
+	pool          *workerPool
+	resizeTimeout time.Duration
+	// scResizeTimeouts override resizeTimeout for PVCs of the StorageClasses.
+	scResizeTimeouts map[string]time.Duration
+	// In-flight resize operations are cancelled after this grace period once the controller stops running.
+	shutdownGracePeriod time.Duration
+	leaderTasks         []func(ctx context.Context)
+	dryRun              bool
+	backoffConfig       BackoffConfig
+	rateLimitConfig     RateLimitConfig
+	status              runStatus
+	logger              logr.Logger
+	tracer              trace.Tracer
+	spanLinks           *spanLinks
+	shrinkRequests      *shrinkRequests
+	workers             *workerTracker
+	kubeClient          kubernetes.Interface
+	eventRecorder       record.EventRecorder
+	eventBroadcaster    record.EventBroadcaster
+	pvLister            corelisters.PersistentVolumeLister
+	pvSynced            cache.InformerSynced
+	pvcLister           corelisters.PersistentVolumeClaimLister
+	pvcSynced           cache.InformerSynced
+	scLister            storagelisters.StorageClassLister
+	scSynced            cache.InformerSynced
+	podLister           corelisters.PodLister
+	podSynced           cache.InformerSynced
+	vaLister            storagelisters.VolumeAttachmentLister
+	vaSynced            cache.InformerSynced
+	quotaLister         corelisters.ResourceQuotaLister
+	quotaSynced         cache.InformerSynced
+	informerFactory     informers.SharedInformerFactory
+	validator           *Validator
+
+	// Metrics are served only if metricConfig is set, and leader election is enabled only if leaderElectionConfig is set.
+	metrics              *metrics
+	metricConfig         *MetricConfig
+	leaderElectionConfig *util.LeaderElectionConfig
+
+	// ResizePolicies are enforced only if policyClient is set.
+	policyClient          versioned.Interface
+	policyLister          resizelisters.ResizePolicyLister
+	policySynced          cache.InformerSynced
+	policyInformerFactory resizeinformers.SharedInformerFactory
 
 	// Extract the actual resize operation as an interface so that we can add metrics flexible.
 	resizeFunc resizeFunc
 }
 
+// NewResizeController creates a controller which resizes volumes by backends of the registry.
 func NewResizeController(
 	identity string,
-	resizer Resizer,
+	registry *Registry,
 	kubeClient kubernetes.Interface,
-	resyncPeriod time.Duration) ResizeController {
-	informerFactory := informers.NewSharedInformerFactory(kubeClient, resyncPeriod)
-	pvInformer := informerFactory.Core().V1().PersistentVolumes()
-	pvcInformer := informerFactory.Core().V1().PersistentVolumeClaims()
-
+	resyncPeriod time.Duration,
+	resizeTimeout time.Duration,
+	options ...Option) ResizeController {
+	// Events are written to the API server only while the controller is running, see Run.
 	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartLogging(glog.Infof)
-	eventBroadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events(v1.NamespaceAll)})
 	eventRecorder := eventBroadcaster.NewRecorder(scheme.Scheme,
 		v1.EventSource{Component: fmt.Sprintf("external-resizer %s", identity)})
 
-	claimQueue := workqueue.NewNamedRateLimitingQueue(
-		workqueue.DefaultControllerRateLimiter(), fmt.Sprintf("%s-pvc", identity))
+	var backends []*backend
+	for _, registered := range registry.Backends() {
+		b := &backend{
+			Backend:      registered,
+			capabilities: GetCapabilities(registered.Resizer),
+			eventRecorder: eventBroadcaster.NewRecorder(scheme.Scheme,
+				v1.EventSource{Component: fmt.Sprintf("external-resizer %s/%s", identity, registered.Name)}),
+			failureEvents: newEventFilter(),
+			dryRunEvents:  newEventFilter(),
+			queueName:     fmt.Sprintf("%s-%s-pvc", identity, registered.Name),
+		}
+		backends = append(backends, b)
+	}
 
 	ctrl := &resizeController{
 		identity:        identity,
-		resizer:         resizer,
+		backends:        backends,
+		threadiness:     DefaultWorkers,
+		resizeTimeout:   resizeTimeout,
 		kubeClient:      kubeClient,
-		pvLister:        pvInformer.Lister(),
-		pvSynced:        pvInformer.Informer().HasSynced,
-		pvcLister:       pvcInformer.Lister(),
-		pvcSynced:       pvcInformer.Informer().HasSynced,
-		claimQueue:      claimQueue,
 		eventRecorder:   eventRecorder,
-		informerFactory: informerFactory,
+		backoffConfig:   DefaultBackoffConfig,
+		rateLimitConfig: DefaultRateLimitConfig,
+		logger:          logging.Logger(),
+		tracer:          otel.GetTracerProvider().Tracer(tracerName),
+		spanLinks:       newSpanLinks(),
+		shrinkRequests:  newShrinkRequests(),
+		metrics:         newMetrics(),
+	}
+	ctrl.eventBroadcaster = eventBroadcaster
+	for _, option := range options {
+		option(ctrl)
+	}
+
+	if ctrl.informerFactory == nil {
+		ctrl.informerFactory = informers.NewSharedInformerFactory(kubeClient, resyncPeriod)
+	}
+	pvInformer := ctrl.informerFactory.Core().V1().PersistentVolumes()
+	pvcInformer := ctrl.informerFactory.Core().V1().PersistentVolumeClaims()
+	scInformer := ctrl.informerFactory.Storage().V1().StorageClasses()
+	podInformer := ctrl.informerFactory.Core().V1().Pods()
+	vaInformer := ctrl.informerFactory.Storage().V1().VolumeAttachments()
+	quotaInformer := ctrl.informerFactory.Core().V1().ResourceQuotas()
+	ctrl.pvLister = pvInformer.Lister()
+	ctrl.pvSynced = pvInformer.Informer().HasSynced
+	ctrl.pvcLister = pvcInformer.Lister()
+	ctrl.pvcSynced = pvcInformer.Informer().HasSynced
+	ctrl.scLister = scInformer.Lister()
+	ctrl.scSynced = scInformer.Informer().HasSynced
+	ctrl.podLister = podInformer.Lister()
+	ctrl.podSynced = podInformer.Informer().HasSynced
+	ctrl.vaLister = vaInformer.Lister()
+	ctrl.vaSynced = vaInformer.Informer().HasSynced
+	ctrl.quotaLister = quotaInformer.Lister()
+	ctrl.quotaSynced = quotaInformer.Informer().HasSynced
+
+	if err := ctrl.backoffConfig.validate(); err != nil {
+		ctrl.logger.Error(err, "Invalid backoff config, use the default one", "config", fmt.Sprintf("%+v", ctrl.backoffConfig))
+		ctrl.backoffConfig = DefaultBackoffConfig
+	}
+	if err := ctrl.rateLimitConfig.validate(); err != nil {
+		ctrl.logger.Error(err, "Invalid rate limit config, use the default one", "config", fmt.Sprintf("%+v", ctrl.rateLimitConfig))
+		ctrl.rateLimitConfig = DefaultRateLimitConfig
+	}
+	if ctrl.threadiness <= 0 {
+		ctrl.logger.Info("Invalid number of workers, use the default one", "workers", ctrl.threadiness, "default", DefaultWorkers)
+		ctrl.threadiness = DefaultWorkers
+	}
+	ctrl.pool = newWorkerPool(ctrl.threadiness)
+	if maxTimeout := ctrl.maxResizeTimeout(); maxTimeout > 0 {
+		// A worker processing a PVC for much longer than the resize timeout is stuck.
+		ctrl.workers = newWorkerTracker(2*maxTimeout + ctrl.shutdownGracePeriod + time.Minute)
+	} else {
+		ctrl.workers = newWorkerTracker(0)
+	}
+
+	for _, b := range ctrl.backends {
+		b.backoff = newBackoffRateLimiter(ctrl.backoffConfig)
+		b.bucket = newBucketRateLimiter(ctrl.rateLimitConfig)
+		b.queueMetrics = ctrl.metrics.queue
+		// Each backend has its own queue and rate limiter so that a slow backend won't block others.
+		b.renewQueue()
+	}
+
+	if ctrl.policyClient != nil {
+		ctrl.policyInformerFactory = resizeinformers.NewSharedInformerFactory(ctrl.policyClient, resyncPeriod)
+		policyInformer := ctrl.policyInformerFactory.Resize().V1alpha1().ResizePolicies()
+		ctrl.policyLister = policyInformer.Lister()
+		ctrl.policySynced = policyInformer.Informer().HasSynced
+		// PVCs refused by policies should be processed again once the policies are changed.
+		policyInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    ctrl.addResizePolicy,
+			UpdateFunc: ctrl.updateResizePolicy,
+			DeleteFunc: ctrl.deleteResizePolicy,
+		})
 	}
+	ctrl.validator = newValidator(ctrl.logger, ctrl.backends, ctrl.pvLister, ctrl.scLister, ctrl.policyLister)
 
 	// Add a resync period as the PVC's request size can be resized again when we handling
 	// a previous resizing request of the same PVC.
@@ -84,143 +232,570 @@ func NewResizeController(
 		DeleteFunc: ctrl.deletePVC,
 	}, resyncPeriod)
 
+	// PVCs refused due to their StorageClass should be processed again once the StorageClass is changed.
+	// Added StorageClasses are ignored, otherwise the initial list would enqueue every PVC once per StorageClass.
+	scInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctrl.updateStorageClass,
+	})
+
+	// PVCs waiting for pods to release them should be processed again once the pods are stopped.
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctrl.updatePod,
+		DeleteFunc: ctrl.deletePod,
+	})
+	vaInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctrl.updateVolumeAttachment,
+		DeleteFunc: ctrl.deleteVolumeAttachment,
+	})
+
+	// PVCs blocked by quotas should be processed again once the quotas are freed or raised.
+	quotaInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.addResourceQuota,
+		UpdateFunc: ctrl.updateResourceQuota,
+		DeleteFunc: ctrl.deleteResourceQuota,
+	})
+
 	return ctrl
 }
 
 func (ctrl *resizeController) addPVC(obj interface{}) {
-	objKey, err := getPVCKey(obj)
-	if err != nil {
+	pvc, ok := obj.(*v1.PersistentVolumeClaim)
+	if !ok {
+		return
+	}
+	ctrl.enqueuePVC(pvc)
+}
+
+func (ctrl *resizeController) updatePVC(oldObj, newObj interface{}) {
+	oldPVC, ok := oldObj.(*v1.PersistentVolumeClaim)
+	if !ok {
 		return
 	}
-	ctrl.claimQueue.Add(objKey)
+	newPVC, ok := newObj.(*v1.PersistentVolumeClaim)
+	if !ok {
+		return
+	}
+	ctrl.shrinkRequests.update(oldPVC, newPVC)
+	// Conditions are written by ourselves, e.g. when a resize fails, processing the PVC again
+	// on such updates would retry it immediately rather than after the backoff.
+	if onlyConditionsChanged(oldPVC, newPVC) {
+		return
+	}
+	ctrl.enqueuePVC(newPVC)
 }
 
-func (ctrl *resizeController) updatePVC(_, newObj interface{}) {
-	ctrl.addPVC(newObj)
+// onlyConditionsChanged returns true if the status conditions of the PVCs differ, but nothing else
+// except object metadata maintained by the API server. Periodic resyncs change nothing and return false.
+func onlyConditionsChanged(oldPVC, newPVC *v1.PersistentVolumeClaim) bool {
+	if equality.Semantic.DeepEqual(oldPVC.Status.Conditions, newPVC.Status.Conditions) {
+		return false
+	}
+	oldPVC, newPVC = oldPVC.DeepCopy(), newPVC.DeepCopy()
+	for _, pvc := range []*v1.PersistentVolumeClaim{oldPVC, newPVC} {
+		pvc.Status.Conditions = nil
+		pvc.ResourceVersion = ""
+		pvc.ManagedFields = nil
+	}
+	return equality.Semantic.DeepEqual(oldPVC, newPVC)
 }
 
 func (ctrl *resizeController) deletePVC(obj interface{}) {
-	objKey, err := getPVCKey(obj)
+	objKey, err := ctrl.getPVCKey(obj)
+	if err != nil {
+		return
+	}
+	for _, b := range ctrl.backends {
+		b.queue().Forget(objKey)
+		b.failureEvents.forget(objKey)
+		b.dryRunEvents.forget(objKey)
+	}
+	ctrl.spanLinks.forget(objKey)
+	ctrl.shrinkRequests.forget(objKey)
+}
+
+// enqueuePVC adds the PVC to the queue of the backend its volume is routed to.
+func (ctrl *resizeController) enqueuePVC(pvc *v1.PersistentVolumeClaim) {
+	if pvc.Spec.VolumeName == "" {
+		return
+	}
+	objKey, err := ctrl.getPVCKey(pvc)
+	if err != nil {
+		return
+	}
+	pv, err := ctrl.pvLister.Get(pvc.Spec.VolumeName)
+	if err != nil {
+		ctrl.pvcLogger(nil, pvc).V(5).Info("Get PV failed, skip the PVC", "err", err)
+		return
+	}
+	b := ctrl.validator.route(pv)
+	if b == nil {
+		ctrl.pvcLogger(nil, pvc).V(5).Info("No backend supports the PV, skip the PVC")
+		return
+	}
+	b.queue().Add(objKey)
+}
+
+func (ctrl *resizeController) enqueuePVCByName(namespace, name string) {
+	pvc, err := ctrl.pvcLister.PersistentVolumeClaims(namespace).Get(name)
+	if err != nil {
+		ctrl.logger.V(5).Info("Get PVC failed", logging.KeyPVC, namespace+"/"+name, "err", err)
+		return
+	}
+	ctrl.enqueuePVC(pvc)
+}
+
+func (ctrl *resizeController) updateStorageClass(oldObj, newObj interface{}) {
+	oldSC, ok := oldObj.(*storagev1.StorageClass)
+	if !ok {
+		return
+	}
+	newSC, ok := newObj.(*storagev1.StorageClass)
+	if !ok {
+		return
+	}
+	// Only these fields decide whether PVCs of the StorageClass are refused.
+	if !expansionAllowed(oldSC) && expansionAllowed(newSC) ||
+		!util.ShrinkAllowed(oldSC.Annotations) && util.ShrinkAllowed(newSC.Annotations) {
+		ctrl.enqueueStorageClassPVCs(newSC)
+	}
+}
+
+func expansionAllowed(sc *storagev1.StorageClass) bool {
+	return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion
+}
+
+func (ctrl *resizeController) enqueueStorageClassPVCs(sc *storagev1.StorageClass) {
+	pvcs, err := ctrl.pvcLister.List(labels.Everything())
 	if err != nil {
+		ctrl.logger.Error(err, "List PVCs failed")
 		return
 	}
-	ctrl.claimQueue.Forget(objKey)
+	for _, pvc := range pvcs {
+		if util.GetPVCStorageClass(pvc) == sc.Name {
+			ctrl.addPVC(pvc)
+		}
+	}
+}
+
+func (ctrl *resizeController) updatePod(oldObj, newObj interface{}) {
+	oldPod, ok := oldObj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	newPod, ok := newObj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	if util.IsPodActive(oldPod) && !util.IsPodActive(newPod) {
+		ctrl.enqueuePodPVCs(newPod)
+	}
+}
+
+func (ctrl *resizeController) deletePod(obj interface{}) {
+	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok && unknown.Obj != nil {
+		obj = unknown.Obj
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	ctrl.enqueuePodPVCs(pod)
+}
+
+func (ctrl *resizeController) enqueuePodPVCs(pod *v1.Pod) {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			ctrl.enqueuePVCByName(pod.Namespace, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+}
+
+func (ctrl *resizeController) updateVolumeAttachment(oldObj, newObj interface{}) {
+	oldVA, ok := oldObj.(*storagev1.VolumeAttachment)
+	if !ok {
+		return
+	}
+	newVA, ok := newObj.(*storagev1.VolumeAttachment)
+	if !ok {
+		return
+	}
+	if oldVA.Status.Attached && !newVA.Status.Attached {
+		ctrl.enqueueVolumeAttachmentPVC(newVA)
+	}
 }
 
-func getPVCKey(obj interface{}) (string, error) {
+func (ctrl *resizeController) deleteVolumeAttachment(obj interface{}) {
+	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok && unknown.Obj != nil {
+		obj = unknown.Obj
+	}
+	va, ok := obj.(*storagev1.VolumeAttachment)
+	if !ok {
+		return
+	}
+	ctrl.enqueueVolumeAttachmentPVC(va)
+}
+
+func (ctrl *resizeController) enqueueVolumeAttachmentPVC(va *storagev1.VolumeAttachment) {
+	pvName := va.Spec.Source.PersistentVolumeName
+	if pvName == nil {
+		return
+	}
+	pv, err := ctrl.pvLister.Get(*pvName)
+	if err != nil {
+		ctrl.logger.V(4).Info("Get PV of volume attachment failed", logging.KeyPV, *pvName, "volumeAttachment", va.Name, "err", err)
+		return
+	}
+	if claimRef := pv.Spec.ClaimRef; claimRef != nil {
+		ctrl.enqueuePVCByName(claimRef.Namespace, claimRef.Name)
+	}
+}
+
+func (ctrl *resizeController) getPVCKey(obj interface{}) (string, error) {
 	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok && unknown.Obj != nil {
 		obj = unknown.Obj
 	}
 	objKey, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	if err != nil {
-		glog.Errorf("Failed to get key from object: %v", err)
+		ctrl.logger.Error(err, "Failed to get key from object")
 		return "", err
 	}
 	return objKey, nil
 }
 
-func (ctrl *resizeController) Run(
-	threadiness int,
-	stopCh <-chan struct{},
-	metricConfig *MetricConfig,
-	leaderElectionConfig *util.LeaderElectionConfig) {
-	run := func(_ context.Context) {
-		defer ctrl.claimQueue.ShutDown()
+func (ctrl *resizeController) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(logging.NewContext(ctx, ctrl.logger))
+	defer cancel()
 
-		glog.Infof("Starting external resizer %s", ctrl.identity)
-		defer glog.Infof("Shutting down external resizer %s", ctrl.identity)
+	for _, b := range ctrl.backends {
+		ctrl.logger.Info("Backend capabilities", logging.KeyBackend, b.Name, "capabilities", fmt.Sprintf("%+v", b.capabilities))
+		ctrl.metrics.recordCapabilities(b.Name, b.capabilities)
+	}
 
-		if metricConfig == nil {
-			ctrl.resizeFunc = ctrl.resizePVC
-		} else {
-			ctrl.resizeFunc = resizeFuncWithMetrics(ctrl.resizePVC)
-			go startMetricsServer(metricConfig)
-		}
+	// Metrics are always recorded, so that they are available by MetricsRegistry without the metrics server.
+	if ctrl.dryRun {
+		ctrl.logger.Info("Running in dry run mode, volumes, PVs and PVCs won't be changed")
+		ctrl.resizeFunc = ctrl.dryRunResizePVC
+	} else {
+		ctrl.resizeFunc = ctrl.metrics.resizeFuncWithMetrics(ctrl.resizePVC)
+	}
 
-		ctrl.informerFactory.Start(stopCh)
-		if !cache.WaitForCacheSync(stopCh, ctrl.pvSynced, ctrl.pvcSynced) {
-			glog.Errorf("Cannot sync pv/pvc caches")
-			return
+	var lock resourcelock.Interface
+	if ctrl.leaderElectionConfig != nil {
+		var err error
+		lock, err = util.NewLeaderLock(ctrl.kubeClient, ctrl.eventRecorder, ctrl.leaderElectionConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create leader election lock: %v", err)
 		}
+	}
 
-		for i := 0; i < threadiness; i++ {
-			go wait.Until(ctrl.syncPVCs, 0, stopCh)
-		}
+	// Events recorded after Run returns are dropped, rather than shutting down the broadcaster,
+	// which panics if an event is recorded afterwards.
+	eventSink := ctrl.eventBroadcaster.StartRecordingToSink(
+		&corev1.EventSinkImpl{Interface: ctrl.kubeClient.CoreV1().Events(v1.NamespaceAll)})
+	defer eventSink.Stop()
+	eventLogging := ctrl.eventBroadcaster.StartLogging(func(format string, args ...interface{}) {
+		ctrl.logger.Info(fmt.Sprintf(format, args...))
+	})
+	defer eventLogging.Stop()
 
-		<-stopCh
+	// The controller is stopped if the metrics server fails.
+	serverErrCh := make(chan error, 1)
+	if ctrl.metricConfig != nil {
+		errCh, err := ctrl.startMetricsServer(ctx, ctrl.metricConfig)
+		if err != nil {
+			return err
+		}
+		go func() {
+			err := <-errCh
+			if err != nil {
+				cancel()
+			}
+			serverErrCh <- err
+		}()
+	} else {
+		serverErrCh <- nil
 	}
 
-	if leaderElectionConfig == nil {
+	var err error
+	if lock == nil {
 		// Leader election disabled.
-		run(context.TODO())
+		err = ctrl.run(ctx, ctx.Done())
 	} else {
-		lock, err := util.NewLeaderLock(ctrl.kubeClient, ctrl.eventRecorder, leaderElectionConfig)
-		if err != nil {
-			glog.Fatalf("Error creating leader election lock: %v", err)
+		err = util.RunAsLeader(ctx, lock, ctrl.leaderElectionConfig, func(leaderCtx context.Context) error {
+			return ctrl.run(leaderCtx, ctx.Done())
+		})
+	}
+
+	// Wait for the metrics server to shut down.
+	cancel()
+	if serverErr := <-serverErrCh; serverErr != nil {
+		return serverErr
+	}
+	return err
+}
+
+// run processes PVCs until ctx is done, which means we are stopping or losing leadership.
+// Then it stops taking new PVCs, waits for in-flight resize operations to finish and cancels
+// them if they are still running after the shutdown grace period.
+// run can be called again after it returns, e.g. when we become the leader again.
+// An error is returned if it fails to start processing PVCs, nil is returned once ctx is done.
+func (ctrl *resizeController) run(ctx context.Context, stopCh <-chan struct{}) error {
+	ctrl.logger.Info("Starting external resizer", "identity", ctrl.identity)
+	defer ctrl.logger.Info("Shutting down external resizer", "identity", ctrl.identity)
+
+	ctrl.status.start()
+	defer ctrl.status.stop()
+
+	for _, b := range ctrl.backends {
+		b.renewQueue()
+	}
+	defer func() {
+		for _, b := range ctrl.backends {
+			b.queue().ShutDown()
+		}
+	}()
+
+	// Informers live across leadership terms, starting them again is a no-op.
+	ctrl.informerFactory.Start(stopCh)
+	cacheSynced := []cache.InformerSynced{
+		ctrl.pvSynced, ctrl.pvcSynced, ctrl.scSynced, ctrl.podSynced, ctrl.vaSynced, ctrl.quotaSynced}
+	if ctrl.policyInformerFactory != nil {
+		ctrl.policyInformerFactory.Start(stopCh)
+		cacheSynced = append(cacheSynced, ctrl.policySynced)
+	}
+	syncCtx, cancelSync := context.WithTimeout(ctx, informerSyncTimeout)
+	defer cancelSync()
+	if !cache.WaitForCacheSync(syncCtx.Done(), cacheSynced...) {
+		if ctx.Err() != nil {
+			// Stopped or lost leadership before caches are synced.
+			return nil
+		}
+		return fmt.Errorf("cannot sync pv/pvc/storage class/pod/volume attachment/resource quota/resize policy caches in %v",
+			informerSyncTimeout)
+	}
+	ctrl.status.setSynced()
+
+	ctrl.reconcileResizeOperations()
+
+	// PVC events received before PV cache synced may be dropped as they can't be routed, process them again.
+	pvcs, err := ctrl.pvcLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("list PVCs failed: %v", err)
+	}
+	for _, pvc := range pvcs {
+		ctrl.enqueuePVC(pvc)
+	}
+
+	// Resize operations are not bound to ctx directly so that they have a chance to finish after ctx is done.
+	opCtx, cancelOps := context.WithCancel(context.Background())
+	defer cancelOps()
+	go func() {
+		<-ctx.Done()
+		timer := time.NewTimer(ctrl.shutdownGracePeriod)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			ctrl.logger.Info("Cancel in-flight resize operations as shutdown grace period exceeded",
+				"gracePeriod", ctrl.shutdownGracePeriod.String())
+			cancelOps()
+		case <-opCtx.Done():
 		}
-		util.RunAsLeader(lock, leaderElectionConfig, run)
+	}()
+
+	ctrl.pool.start(ctrl.backends, func(b *backend) { ctrl.syncPVCs(opCtx, b) }, ctx.Done())
+
+	var wg sync.WaitGroup
+	for _, task := range ctrl.leaderTasks {
+		task := task
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			task(ctx)
+		}()
 	}
+
+	<-ctx.Done()
+	// Shut down queues so that idle workers return immediately, busy workers return after their current PVC is processed.
+	for _, b := range ctrl.backends {
+		b.queue().ShutDown()
+	}
+	ctrl.pool.wait()
+	wg.Wait()
+	return nil
 }
 
-func (ctrl *resizeController) syncPVCs() {
-	key, quit := ctrl.claimQueue.Get()
+func (ctrl *resizeController) syncPVCs(ctx context.Context, b *backend) {
+	queue := b.queue()
+	key, quit := queue.Get()
 	if quit {
 		return
 	}
-	defer ctrl.claimQueue.Done(key)
+	defer queue.Done(key)
 
-	if err := ctrl.syncPVC(key.(string)); err != nil {
+	workerKey := b.Name + "/" + key.(string)
+	ctrl.workers.start(workerKey)
+	defer ctrl.workers.done(workerKey)
+
+	if err := ctrl.syncPVC(ctx, b, key.(string)); err != nil {
 		// Put PVC back to the queue so that we can retry later.
-		ctrl.claimQueue.AddRateLimited(key)
+		if retryErr, ok := err.(*retryAfterError); ok {
+			queue.AddAfter(key, retryErr.delay)
+		} else if isRetryable(err) {
+			queue.AddRateLimited(key)
+		} else {
+			// Not retryable errors are retried once the PVC or the volume is changed.
+			queue.Forget(key)
+		}
 	} else {
-		ctrl.claimQueue.Forget(key)
+		queue.Forget(key)
+		b.failureEvents.forget(key.(string))
 	}
 }
 
-func (ctrl *resizeController) syncPVC(key string) error {
-	glog.V(4).Infof("Started PVC processing %q", key)
+func (ctrl *resizeController) syncPVC(ctx context.Context, b *backend, key string) error {
+	logger := ctrl.logger.WithValues(logging.KeyPVC, key, logging.KeyBackend, b.Name)
+	logger.V(4).Info("Started PVC processing")
 
 	namespace, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
-		glog.Errorf("Split meta namespace key of pvc %s failed: %v", key, err)
+		logger.Error(err, "Split meta namespace key of PVC failed")
 		return err
 	}
 
 	pvc, err := ctrl.pvcLister.PersistentVolumeClaims(namespace).Get(name)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
-			glog.V(3).Infof("PVC %s/%s is deleted, no need to process it", namespace, name)
+			logger.V(3).Info("PVC is deleted, no need to process it")
 			return nil
 		}
-		glog.Errorf("Get PVC %s/%s failed: %v", namespace, name, err)
+		logger.Error(err, "Get PVC failed")
 		return err
 	}
+	logger = ctrl.pvcLogger(b, pvc)
 
-	if !ctrl.pvcNeedResize(pvc) {
-		glog.V(4).Infof("No need to resize PVC %q", util.PVCKey(pvc))
+	if pvc.Status.Phase != v1.ClaimBound || pvc.Spec.VolumeName == "" {
+		logger.V(4).Info("No need to resize PVC as it isn't bound")
 		return nil
 	}
 
 	pv, err := ctrl.pvLister.Get(pvc.Spec.VolumeName)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
-			glog.V(3).Infof("PV %s is deleted, no need to process it", pvc.Spec.VolumeName)
+			logger.V(3).Info("PV is deleted, no need to process it")
 			return nil
 		}
-		glog.Errorf("Get PV %q of pvc %q failed: %v", pvc.Spec.VolumeName, util.PVCKey(pvc), err)
+		logger.Error(err, "Get PV failed")
+		return err
+	}
+
+	shrinking := ctrl.pvcShrinking(pvc, pv)
+	if !shrinking && !ctrl.pvcNeedResize(pvc) {
+		logger.V(4).Info("No need to resize PVC")
+		return nil
+	}
+
+	if !ctrl.pvNeedResize(b, pvc, pv, shrinking) {
+		logger.V(4).Info("No need to resize PV")
+		return nil
+	}
+
+	if rejection, err := ctrl.validator.check(b, pvc, pv, shrinking); err != nil {
+		logger.Error(err, "Validate resize request failed")
 		return err
+	} else if rejection != nil {
+		logger.V(3).Info("Refuse to resize PVC", "reason", rejection.Reason, "message", rejection.Message)
+		if rejection.RetryAfter > 0 {
+			b.queue().AddAfter(key, rejection.RetryAfter)
+		}
+		return ctrl.markPVCResizeRejected(b, pvc, rejection.Reason, rejection.Message)
+	}
+
+	if shrinking {
+		if inUse, message, err := ctrl.shrinkingVolumeInUse(pvc); err != nil {
+			logger.Error(err, "Check if PVC is in use failed")
+			return err
+		} else if inUse {
+			logger.V(3).Info("Refuse to shrink PVC", "message", message)
+			return ctrl.markPVCResizeRejected(b, pvc, util.VolumeShrinkRejected, message)
+		}
+	} else {
+		if exceeds, message, err := ctrl.expansionExceedsQuota(pvc); err != nil {
+			logger.Error(err, "Check quota of PVC failed")
+			return err
+		} else if exceeds {
+			logger.V(3).Info("Defer expansion of PVC", "message", message)
+			return ctrl.markPVCResizeRejected(b, pvc, util.VolumeExpansionExceedsQuota, message)
+		}
+
+		if !b.capabilities.OnlineExpansion {
+			// Offline expansion mode, the volume must be released by all pods before it can be expanded.
+			if inUse, message, err := ctrl.volumeInUse(b, pvc, pv); err != nil {
+				logger.Error(err, "Check if PVC is in use failed")
+				return err
+			} else if inUse {
+				logger.V(3).Info("Defer expansion of PVC", "message", message)
+				return ctrl.markPVCWaitingForDetach(b, pvc, message)
+			}
+		}
 	}
 
-	if !ctrl.pvNeedResize(pvc, pv) {
-		glog.V(4).Infof("No need to resize PV %q", pv.Name)
+	if op, err := util.GetResizeOperation(pv); err == nil && op != nil && op.FailureReason != "" &&
+		op.TargetSize.Cmp(pvc.Spec.Resources.Requests[v1.ResourceStorage]) == 0 {
+		logger.V(4).Info("Resize failed before, wait for the request size to be changed",
+			"targetSize", op.TargetSize.String(), "failureReason", op.FailureReason)
 		return nil
 	}
 
-	return ctrl.resizeFunc(pvc, pv)
+	if err := b.bucket.wait(ctx); err != nil {
+		logger.Error(err, "Wait for rate limit of backend failed")
+		return err
+	}
+	return ctrl.resizeFunc(ctx, b, pvc, pv)
+}
+
+// volumeInUse checks if the volume is used by any pod or still attached to any node,
+// returns a message explaining what we are waiting for if so.
+func (ctrl *resizeController) volumeInUse(
+	b *backend,
+	pvc *v1.PersistentVolumeClaim,
+	pv *v1.PersistentVolume) (bool, string, error) {
+	pods, err := util.GetPodsUsingPVC(pvc, ctrl.podLister)
+	if err != nil {
+		return false, "", err
+	}
+	if len(pods) > 0 {
+		return true, fmt.Sprintf("Backend %s only supports offline expansion, waiting for pod %s to stop using the volume",
+			b.Name, pods[0].Name), nil
+	}
+
+	attachments, err := util.GetVolumeAttachments(pv, ctrl.vaLister)
+	if err != nil {
+		return false, "", err
+	}
+	if len(attachments) > 0 {
+		return true, fmt.Sprintf("Backend %s only supports offline expansion, waiting for the volume to be detached from node %s",
+			b.Name, attachments[0].Spec.NodeName), nil
+	}
+
+	return false, "", nil
+}
+
+// shrinkingVolumeInUse checks if the volume to shrink is used by any pod,
+// returns a message explaining the reason if so.
+func (ctrl *resizeController) shrinkingVolumeInUse(pvc *v1.PersistentVolumeClaim) (bool, string, error) {
+	pods, err := util.GetPodsUsingPVC(pvc, ctrl.podLister)
+	if err != nil {
+		return false, "", err
+	}
+	if len(pods) > 0 {
+		return true, fmt.Sprintf("Volume can't be shrunk while it is used by pod %s, "+
+			"the shrink will be retried after all pods using it are stopped", pods[0].Name), nil
+	}
+	return false, "", nil
 }
 
 func (ctrl *resizeController) pvcNeedResize(pvc *v1.PersistentVolumeClaim) bool {
-	// Only Bound pvc can be expanded.
+	// Only Bound pvc can be resized.
 	if pvc.Status.Phase != v1.ClaimBound {
 		return false
 	}
@@ -232,16 +807,21 @@ func (ctrl *resizeController) pvcNeedResize(pvc *v1.PersistentVolumeClaim) bool
 	return requestSize.Cmp(actualSize) > 0
 }
 
-func (ctrl *resizeController) pvNeedResize(pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) bool {
-	if !ctrl.resizer.CanSupport(pv) {
-		glog.V(4).Infof("Resizer %q doesn't support PV %q", ctrl.identity, pv.Name)
+func (ctrl *resizeController) pvNeedResize(
+	b *backend,
+	pvc *v1.PersistentVolumeClaim,
+	pv *v1.PersistentVolume,
+	shrinking bool) bool {
+	if !b.Resizer.CanSupport(pv) {
+		ctrl.pvcLogger(b, pvc).V(4).Info("Backend doesn't support the PV")
 		return false
 	}
 
 	pvSize := pv.Spec.Capacity[v1.ResourceStorage]
 	requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
-	if pvSize.Cmp(requestSize) >= 0 {
-		// If PV size is equal or bigger than request size, that means we have already resized PV.
+	if (!shrinking && pvSize.Cmp(requestSize) >= 0) || (shrinking && pvSize.Cmp(requestSize) <= 0) {
+		// If PV size is equal or bigger than request size (equal or smaller if we are shrinking volume),
+		// that means we have already resized PV.
 		// In this case we need to check PVC's condition.
 		// 1. If PVC in PersistentVolumeClaimResizing condition, we should continue to perform the
 		//    resizing operation as we need to know if file system resize if required. (What's more,
@@ -256,7 +836,7 @@ func (ctrl *resizeController) pvNeedResize(pvc *v1.PersistentVolumeClaim, pv *v1
 		return true
 	}
 
-	// PV size is smaller than request size, we need to resize the volume.
+	// PV size doesn't reach request size, we need to resize the volume.
 	return true
 }
 
@@ -264,61 +844,209 @@ func (ctrl *resizeController) pvNeedResize(pvc *v1.PersistentVolumeClaim, pv *v1
 // 1. Mark pvc as resizing.
 // 2. Resize the pv and volume.
 // 3. Mark pvc as resizing finished(no error, no need to resize fs), need resizing fs or resize failed.
-func (ctrl *resizeController) resizePVC(pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) error {
-	if updatedPVC, err := ctrl.markPVCResizeInProgress(pvc); err != nil {
-		glog.Errorf("Mark pvc %q as resizing failed: %v", util.PVCKey(pvc), err)
+func (ctrl *resizeController) resizePVC(
+	ctx context.Context,
+	b *backend,
+	pvc *v1.PersistentVolumeClaim,
+	pv *v1.PersistentVolume) (err error) {
+	key := util.PVCKey(pvc)
+	requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	attempt := b.backoff.NumRequeues(key) + 1
+	// The logger is passed to the resizer in the context, so that logs of the resizer carry the same fields.
+	logger := ctrl.pvcLogger(b, pvc).WithValues(logging.KeyAttempt, attempt, logging.KeyRequestedSize, requestSize.String())
+	ctx = logging.NewContext(ctx, logger)
+	ctx, span := ctrl.startSpan(ctx, "ResizePVC", trace.WithAttributes(
+		attribute.String("pvc", key),
+		attribute.String("pv", pv.Name),
+		attribute.String("backend", b.Name),
+		attribute.String("requestedSize", requestSize.String()),
+		attribute.Int("attempt", attempt),
+	), trace.WithLinks(ctrl.spanLinks.links(key)...))
+	defer func() { endSpan(span, err) }()
+	ctrl.spanLinks.set(key, span.SpanContext())
+
+	_, stageSpan := ctrl.startSpan(ctx, "MarkPVCResizeInProgress")
+	updatedPVC, err := ctrl.markPVCResizeInProgress(pvc)
+	endSpan(stageSpan, err)
+	if err != nil {
+		logger.Error(err, "Mark PVC as resizing failed")
 		return err
 	} else if updatedPVC != nil {
 		pvc = updatedPVC
 	}
 
 	// Record an event to indicate that external resizer is resizing this volume.
-	ctrl.eventRecorder.Event(pvc, v1.EventTypeNormal, util.VolumeResizing,
+	b.eventRecorder.Event(pvc, v1.EventTypeNormal, util.VolumeResizing,
 		fmt.Sprintf("External resizer is resizing volume %s", pv.Name))
 
-	err := func() error {
-		newSize, fsResizeRequired, err := ctrl.resizeVolume(pvc, pv)
+	err = func() (err error) {
+		newSize, fsResizeRequired, err := ctrl.resizeVolume(ctx, b, pvc, pv)
 		if err != nil {
 			return err
 		}
 
 		if fsResizeRequired {
 			// Resize volume succeeded and need to resize file system by kubelet, mark it as file system resizing required.
-			return ctrl.markPVCAsFSResizeRequired(pvc)
+			_, stageSpan := ctrl.startSpan(ctx, "MarkPVCAsFSResizeRequired")
+			defer func() { endSpan(stageSpan, err) }()
+			return ctrl.markPVCAsFSResizeRequired(b, pvc)
 		}
 		// Resize volume succeeded and no need to resize file system by kubelet, mark it as resizing finished.
-		return ctrl.markPVCResizeFinished(pvc, newSize)
+		_, stageSpan := ctrl.startSpan(ctx, "MarkPVCResizeFinished")
+		defer func() { endSpan(stageSpan, err) }()
+		return ctrl.markPVCResizeFinished(b, pvc, newSize)
 	}()
 
 	if err != nil {
-		// Record an event to indicate that resize operation is failed.
-		ctrl.eventRecorder.Eventf(pvc, v1.EventTypeWarning, util.VolumeResizeFailed, err.Error())
+		switch GetErrorKind(err) {
+		case ErrorKindTerminal, ErrorKindInfeasible:
+			return ctrl.markPVCResizeAborted(b, pvc, err)
+		case ErrorKindNeedsDetach:
+			if markErr := ctrl.markPVCWaitingForDetach(b, pvc, err.Error()); markErr != nil {
+				return markErr
+			}
+			return err
+		default:
+			return ctrl.markPVCResizeFailed(b, pvc, err)
+		}
 	}
 
-	return err
+	ctrl.spanLinks.forget(key)
+	ctrl.shrinkRequests.forget(key)
+	return nil
 }
 
 // resizeVolume resize the volume to request size, and update PV's capacity if succeeded.
 func (ctrl *resizeController) resizeVolume(
+	ctx context.Context,
+	b *backend,
 	pvc *v1.PersistentVolumeClaim,
 	pv *v1.PersistentVolume) (resource.Quantity, bool, error) {
-	requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
-	newSize, fsResizeRequired, err := ctrl.resizer.Resize(pv, requestSize)
+	if timeout := ctrl.resizeTimeoutOf(pvc); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	logger := logging.FromContext(ctx)
+	req, err := ctrl.newResizeRequest(pvc, pv)
+	if err != nil {
+		logger.Error(err, "Build resize request failed")
+		return pv.Spec.Capacity[v1.ResourceStorage], false, fmt.Errorf("resize volume %s failed: %v", pv.Name, err)
+	}
+
+	_, span := ctrl.startSpan(ctx, "StartResizeOperation")
+	err = ctrl.startResizeOperation(ctx, b, req)
+	if req.Operation != nil {
+		span.SetAttributes(attribute.String("operation", req.Operation.ID), attribute.Bool("resumed", req.Resumed))
+	}
+	endSpan(span, err)
+	if err != nil {
+		logger.Error(err, "Record resize operation failed")
+		return pv.Spec.Capacity[v1.ResourceStorage], false, fmt.Errorf("resize volume %s failed: %v", pv.Name, err)
+	}
+	pv = req.PV
+
+	// The span is passed to the resizer in the context, so that spans of the resizer are its children.
+	resizeCtx, span := ctrl.startSpan(ctx, "Resizer.Resize")
+	startTime := time.Now()
+	newSize, fsResizeRequired, err := b.Resizer.Resize(resizeCtx, req)
+	ctrl.metrics.observeBackendResize(b.Name, startTime, err)
+	endSpan(span, err)
 	if err != nil {
-		glog.Errorf("Resize volume %q by resizer %q failed: %v", pv.Name, ctrl.identity, err)
-		return newSize, fsResizeRequired, fmt.Errorf("resize volume %s failed: %v", pv.Name, err)
+		logger.Error(err, "Resize volume failed", "kind", string(GetErrorKind(err)))
+		if kind := GetErrorKind(err); kind == ErrorKindTerminal || kind == ErrorKindInfeasible {
+			// Record the failure so that the operation won't be retried until the request size is changed.
+			req.Operation.FailureReason = string(kind)
+			if _, recordErr := util.SetResizeOperation(pv, req.Operation, ctrl.kubeClient); recordErr != nil {
+				logger.Error(recordErr, "Record failure of resize operation failed", "operation", req.Operation.ID)
+			}
+		}
+		return newSize, fsResizeRequired, wrapResizeError(err, "resize volume %s failed", pv.Name)
 	}
-	glog.V(4).Infof("Resize volume succeeded for volume %q, start to update PV's capacity", pv.Name)
+	logger.V(4).Info("Resize volume succeeded, start to update PV's capacity", "newSize", newSize.String())
 
-	if err := util.UpdatePVCapacity(pv, newSize, ctrl.kubeClient); err != nil {
-		glog.Errorf("Update capacity of PV %q to %s failed: %v", pv.Name, newSize.String(), err)
+	_, span = ctrl.startSpan(ctx, "UpdatePVCapacity")
+	startTime = time.Now()
+	err = util.UpdatePVCapacity(pv, newSize, ctrl.kubeClient)
+	ctrl.metrics.observeAPIRequest("update_pv_capacity", startTime, err)
+	endSpan(span, err)
+	if err != nil {
+		logger.Error(err, "Update capacity of PV failed", "newSize", newSize.String())
 		return newSize, fsResizeRequired, err
 	}
-	glog.V(4).Infof("Update capacity of PV %q to %s succeeded", pv.Name, newSize.String())
+	ctrl.metrics.recordBytesAdded(b.Name, pvc, pv.Spec.Capacity[v1.ResourceStorage], newSize)
+	logger.V(4).Info("Update capacity of PV succeeded", "newSize", newSize.String())
 
 	return newSize, fsResizeRequired, nil
 }
 
+// resizeTimeoutOf returns the timeout of resizing pvc, 0 means no timeout.
+func (ctrl *resizeController) resizeTimeoutOf(pvc *v1.PersistentVolumeClaim) time.Duration {
+	if timeout, ok := ctrl.scResizeTimeouts[util.GetPVCStorageClass(pvc)]; ok {
+		return timeout
+	}
+	return ctrl.resizeTimeout
+}
+
+// maxResizeTimeout returns the max timeout of resizing any PVC, 0 if any PVC is resized without timeout.
+func (ctrl *resizeController) maxResizeTimeout() time.Duration {
+	maxTimeout := ctrl.resizeTimeout
+	for _, timeout := range ctrl.scResizeTimeouts {
+		if timeout <= 0 || maxTimeout <= 0 {
+			return 0
+		}
+		if timeout > maxTimeout {
+			maxTimeout = timeout
+		}
+	}
+	return maxTimeout
+}
+
+// newResizeRequest collects parameters and secrets from PVC's StorageClass.
+func (ctrl *resizeController) newResizeRequest(
+	pvc *v1.PersistentVolumeClaim,
+	pv *v1.PersistentVolume) (*ResizeRequest, error) {
+	req := &ResizeRequest{
+		PV:          pv,
+		PVC:         pvc,
+		RequestSize: pvc.Spec.Resources.Requests[v1.ResourceStorage],
+	}
+
+	scName := util.GetPVCStorageClass(pvc)
+	if scName == "" {
+		return req, nil
+	}
+	sc, err := ctrl.scLister.Get(scName)
+	if err != nil {
+		return nil, fmt.Errorf("get StorageClass %s failed: %v", scName, err)
+	}
+	req.Parameters = util.GetResizerParameters(sc.Parameters)
+
+	secretRef, err := util.GetSecretReference(sc.Parameters, pvc, pv)
+	if err != nil {
+		return nil, err
+	}
+	if secretRef != nil {
+		req.Secrets, err = util.GetSecretData(secretRef, ctrl.kubeClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// patchPVCStatus patches PVC status and records the latency.
+func (ctrl *resizeController) patchPVCStatus(
+	oldPVC *v1.PersistentVolumeClaim,
+	newPVC *v1.PersistentVolumeClaim) (*v1.PersistentVolumeClaim, error) {
+	startTime := time.Now()
+	updatedPVC, err := util.PatchPVCStatus(oldPVC, newPVC, ctrl.kubeClient)
+	ctrl.metrics.observeAPIRequest("patch_pvc_status", startTime, err)
+	return updatedPVC, err
+}
+
 func (ctrl *resizeController) markPVCResizeInProgress(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolumeClaim, error) {
 	// Mark PVC as Resize Started
 	progressCondition := v1.PersistentVolumeClaimCondition{
@@ -329,26 +1057,133 @@ func (ctrl *resizeController) markPVCResizeInProgress(pvc *v1.PersistentVolumeCl
 	newPVC := pvc.DeepCopy()
 	newPVC.Status.Conditions = util.MergeResizeConditionsOfPVC(newPVC.Status.Conditions,
 		[]v1.PersistentVolumeClaimCondition{progressCondition})
-	return util.PatchPVCStatus(pvc, newPVC, ctrl.kubeClient)
+	return ctrl.patchPVCStatus(pvc, newPVC)
 }
 
-func (ctrl *resizeController) markPVCResizeFinished(pvc *v1.PersistentVolumeClaim, newSize resource.Quantity) error {
+// markPVCResizeRejected sets PVC's Resizing condition to False with the reason and message
+// explaining why the resize request is refused, and records a warning event.
+// Nothing is done if the PVC is already rejected with the same reason and message.
+func (ctrl *resizeController) markPVCResizeRejected(b *backend, pvc *v1.PersistentVolumeClaim, reason, message string) error {
+	return ctrl.markPVCResizeBlocked(b, pvc, v1.EventTypeWarning, reason, message)
+}
+
+// markPVCWaitingForDetach sets PVC's Resizing condition to False with the message explaining
+// what the expansion is waiting for. The expansion is resumed once the volume is released.
+func (ctrl *resizeController) markPVCWaitingForDetach(b *backend, pvc *v1.PersistentVolumeClaim, message string) error {
+	return ctrl.markPVCResizeBlocked(b, pvc, v1.EventTypeNormal, util.WaitingForDetach, message)
+}
+
+func (ctrl *resizeController) markPVCResizeBlocked(
+	b *backend,
+	pvc *v1.PersistentVolumeClaim,
+	eventType, reason, message string) error {
+	if ctrl.dryRun {
+		// The condition is never written in dry run mode, deduplicate by the events recorded instead.
+		if b.dryRunEvents.shouldRecord(util.PVCKey(pvc), reason+": "+message) {
+			ctrl.pvcLogger(b, pvc).Info("[dry run] would set Resizing condition to False", "reason", reason, "message", message)
+			b.eventRecorder.Event(pvc, eventType, reason, "[dry run] "+message)
+		}
+		return nil
+	}
+
+	if condition := util.GetPVCCondition(pvc, v1.PersistentVolumeClaimResizing); condition != nil &&
+		condition.Status == v1.ConditionFalse && condition.Reason == reason && condition.Message == message {
+		return nil
+	}
+
+	blockedCondition := v1.PersistentVolumeClaimCondition{
+		Type:               v1.PersistentVolumeClaimResizing,
+		Status:             v1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+
+	newPVC := pvc.DeepCopy()
+	newPVC.Status.Conditions = util.MergeResizeConditionsOfPVC(newPVC.Status.Conditions,
+		[]v1.PersistentVolumeClaimCondition{blockedCondition})
+	if _, err := ctrl.patchPVCStatus(pvc, newPVC); err != nil {
+		ctrl.pvcLogger(b, pvc).Error(err, "Mark PVC as resize blocked failed", "reason", reason)
+		return err
+	}
+
+	b.eventRecorder.Event(pvc, eventType, reason, message)
+	return nil
+}
+
+// markPVCResizeFailed takes the next retry delay from the backoff of the backend, records the failure count
+// and next retry time in PVC's Resizing condition, and records a warning event unless the same failure
+// is recorded recently. The returned error tells syncPVCs to retry after the delay.
+func (ctrl *resizeController) markPVCResizeFailed(b *backend, pvc *v1.PersistentVolumeClaim, resizeErr error) error {
+	key := util.PVCKey(pvc)
+	delay := b.backoff.When(key)
+	failures := b.backoff.NumRequeues(key)
+	message := fmt.Sprintf("Resize failed %d time(s), next retry at %s: %v",
+		failures, time.Now().Add(delay).UTC().Format(time.RFC3339), resizeErr)
+	reason := util.VolumeResizeFailed
+	if GetErrorKind(resizeErr) == ErrorKindQuotaExceeded {
+		reason = util.VolumeResizeBackendQuotaExceeded
+	}
+
+	failedCondition := v1.PersistentVolumeClaimCondition{
+		Type:               v1.PersistentVolumeClaimResizing,
+		Status:             v1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	newPVC := pvc.DeepCopy()
+	newPVC.Status.Conditions = util.MergeResizeConditionsOfPVC(newPVC.Status.Conditions,
+		[]v1.PersistentVolumeClaimCondition{failedCondition})
+	if _, err := ctrl.patchPVCStatus(pvc, newPVC); err != nil {
+		ctrl.pvcLogger(b, pvc).Error(err, "Mark PVC as resize failed failed")
+	}
+
+	if b.failureEvents.shouldRecord(key, resizeErr.Error()) {
+		b.eventRecorder.Event(pvc, v1.EventTypeWarning, reason, message)
+	} else {
+		ctrl.pvcLogger(b, pvc).V(4).Info("Suppress duplicate resize failure event")
+	}
+
+	return &retryAfterError{err: resizeErr, delay: delay}
+}
+
+// markPVCResizeAborted sets PVC's Resizing condition to False with the terminal or infeasible error,
+// the resize won't be retried until the request size of the PVC is changed.
+func (ctrl *resizeController) markPVCResizeAborted(b *backend, pvc *v1.PersistentVolumeClaim, resizeErr error) error {
+	reason := util.VolumeResizeAborted
+	message := fmt.Sprintf("Resize can't be completed and won't be retried until the request size is changed: %v", resizeErr)
+	if GetErrorKind(resizeErr) == ErrorKindInfeasible {
+		reason = util.VolumeResizeInfeasible
+		message = fmt.Sprintf("Request size can't be satisfied, the resize won't be retried until the request size is changed: %v",
+			resizeErr)
+	}
+	if err := ctrl.markPVCResizeBlocked(b, pvc, v1.EventTypeWarning, reason, message); err != nil {
+		return err
+	}
+	return resizeErr
+}
+
+func (ctrl *resizeController) markPVCResizeFinished(
+	b *backend,
+	pvc *v1.PersistentVolumeClaim,
+	newSize resource.Quantity) error {
 	newPVC := pvc.DeepCopy()
 	newPVC.Status.Capacity[v1.ResourceStorage] = newSize
 	newPVC.Status.Conditions = util.MergeResizeConditionsOfPVC(pvc.Status.Conditions, []v1.PersistentVolumeClaimCondition{})
-	_, err := util.PatchPVCStatus(pvc, newPVC, ctrl.kubeClient)
+	_, err := ctrl.patchPVCStatus(pvc, newPVC)
 	if err != nil {
-		glog.Errorf("Mark PVC %q as resize finished failed: %v", util.PVCKey(pvc), err)
+		ctrl.pvcLogger(b, pvc).Error(err, "Mark PVC as resize finished failed")
 		return err
 	}
 
-	glog.V(4).Infof("Resize PVC %q finished", util.PVCKey(pvc))
-	ctrl.eventRecorder.Eventf(pvc, v1.EventTypeNormal, util.VolumeResizeSuccess, "Resize volume succeeded")
+	ctrl.pvcLogger(b, pvc).V(4).Info("Resize PVC finished")
+	b.eventRecorder.Eventf(pvc, v1.EventTypeNormal, util.VolumeResizeSuccess, "Resize volume succeeded")
 
 	return nil
 }
 
-func (ctrl *resizeController) markPVCAsFSResizeRequired(pvc *v1.PersistentVolumeClaim) error {
+func (ctrl *resizeController) markPVCAsFSResizeRequired(b *backend, pvc *v1.PersistentVolumeClaim) error {
 	pvcCondition := v1.PersistentVolumeClaimCondition{
 		Type:               v1.PersistentVolumeClaimFileSystemResizePending,
 		Status:             v1.ConditionTrue,
@@ -358,14 +1193,14 @@ func (ctrl *resizeController) markPVCAsFSResizeRequired(pvc *v1.PersistentVolume
 	newPVC := pvc.DeepCopy()
 	newPVC.Status.Conditions = util.MergeResizeConditionsOfPVC(newPVC.Status.Conditions,
 		[]v1.PersistentVolumeClaimCondition{pvcCondition})
-	_, err := util.PatchPVCStatus(pvc, newPVC, ctrl.kubeClient)
+	_, err := ctrl.patchPVCStatus(pvc, newPVC)
 	if err != nil {
-		glog.Errorf("Mark PVC %q as file system resize required failed: %v", util.PVCKey(pvc), err)
+		ctrl.pvcLogger(b, pvc).Error(err, "Mark PVC as file system resize required failed")
 		return err
 	}
 
-	glog.V(4).Infof("Mark PVC %q as file system resize required", util.PVCKey(pvc))
-	ctrl.eventRecorder.Eventf(pvc, v1.EventTypeNormal,
+	ctrl.pvcLogger(b, pvc).V(4).Info("Mark PVC as file system resize required")
+	b.eventRecorder.Eventf(pvc, v1.EventTypeNormal,
 		util.FileSystemResizeRequired, "Require file system resize of volume on node")
 
 	return err
@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mlmhl/external-resizer/util"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// failingResizer fails all resizes and records when it is called.
+type failingResizer struct {
+	lock  sync.Mutex
+	calls []time.Time
+}
+
+func (r *failingResizer) CanSupport(*v1.PersistentVolume) bool {
+	return true
+}
+
+func (r *failingResizer) Resize(*v1.PersistentVolume, resource.Quantity) (resource.Quantity, bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.calls = append(r.calls, time.Now())
+	return resource.Quantity{}, false, fmt.Errorf("backend is unavailable")
+}
+
+func (r *failingResizer) callTimes() []time.Time {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]time.Time(nil), r.calls...)
+}
+
+func newResizeTestObjects() (*v1.PersistentVolume, *v1.PersistentVolumeClaim, *storagev1.StorageClass) {
+	allowVolumeExpansion := true
+	sc := &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: "sc"},
+		Provisioner:          "test",
+		AllowVolumeExpansion: &allowVolumeExpansion,
+	}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv"},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity:         v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+			StorageClassName: sc.Name,
+			ClaimRef:         &v1.ObjectReference{Namespace: "default", Name: "pvc"},
+		},
+	}
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "default", ResourceVersion: "1"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &sc.Name,
+			VolumeName:       pv.Name,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("2Gi")},
+			},
+		},
+		Status: v1.PersistentVolumeClaimStatus{
+			Phase:    v1.ClaimBound,
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+		},
+	}
+	return pv, pvc, sc
+}
+
+func TestFailedResizeIsRetriedAfterBackoff(t *testing.T) {
+	pv, pvc, sc := newResizeTestObjects()
+	kubeClient := fake.NewSimpleClientset(pv, pvc, sc)
+	resizer := &failingResizer{}
+	registry := NewRegistry()
+	if err := registry.Register(Backend{Name: "test", Resizer: NewContextResizer(resizer)}); err != nil {
+		t.Fatal(err)
+	}
+	backoff := BackoffConfig{Base: 200 * time.Millisecond, Max: time.Minute}
+	ctrl := NewResizeController("test", registry, kubeClient, time.Hour, time.Minute, WithBackoff(backoff))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := ctrl.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Retries happen after 200ms, 400ms and 800ms, status updates of the failures must not trigger more.
+	calls := resizer.callTimes()
+	if len(calls) < 3 || len(calls) > 4 {
+		t.Fatalf("resizer is called %d times in 2s, want 3 or 4", len(calls))
+	}
+	for i := 1; i < len(calls); i++ {
+		want := backoff.Base << uint(i-1)
+		if gap := calls[i].Sub(calls[i-1]); gap < want {
+			t.Errorf("retry %d happens %v after the previous call, want at least %v", i, gap, want)
+		}
+	}
+}
+
+func TestOnlyConditionsChanged(t *testing.T) {
+	_, pvc, _ := newResizeTestObjects()
+
+	conditionChanged := pvc.DeepCopy()
+	conditionChanged.ResourceVersion = "2"
+	conditionChanged.Status.Conditions = []v1.PersistentVolumeClaimCondition{
+		{Type: v1.PersistentVolumeClaimResizing, Status: v1.ConditionFalse, Reason: "Failed"},
+	}
+	if !onlyConditionsChanged(pvc, conditionChanged) {
+		t.Errorf("onlyConditionsChanged() = false for a condition update")
+	}
+
+	if onlyConditionsChanged(pvc, pvc.DeepCopy()) {
+		t.Errorf("onlyConditionsChanged() = true for a resync")
+	}
+
+	sizeChanged := conditionChanged.DeepCopy()
+	sizeChanged.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("3Gi")
+	if onlyConditionsChanged(pvc, sizeChanged) {
+		t.Errorf("onlyConditionsChanged() = true for a request size update")
+	}
+}
+
+func TestMetricsArePerController(t *testing.T) {
+	newController := func() *resizeController {
+		registry := NewRegistry()
+		if err := registry.Register(Backend{Name: "test", Resizer: NewContextResizer(&failingResizer{})}); err != nil {
+			t.Fatal(err)
+		}
+		// Both controllers have the same identity, so their queues have the same name.
+		return NewResizeController("test", registry, fake.NewSimpleClientset(), time.Hour, time.Minute).(*resizeController)
+	}
+	ctrl1, ctrl2 := newController(), newController()
+	if ctrl1.MetricsRegistry() == ctrl2.MetricsRegistry() {
+		t.Fatalf("controllers share the metrics registry")
+	}
+
+	b1 := ctrl1.backends[0]
+	b1.queue().Add("default/pvc")
+	if depth := testutil.ToFloat64(ctrl1.metrics.queue.depth.WithLabelValues(b1.queueName)); depth != 1 {
+		t.Errorf("queue depth of the first controller is %v, want 1", depth)
+	}
+	if depth := testutil.ToFloat64(ctrl2.metrics.queue.depth.WithLabelValues(b1.queueName)); depth != 0 {
+		t.Errorf("queue depth of the second controller is %v, want 0", depth)
+	}
+}
+
+func TestResizeMetricsAreRecordedWithoutMetricsServer(t *testing.T) {
+	pv, pvc, sc := newResizeTestObjects()
+	registry := NewRegistry()
+	if err := registry.Register(Backend{Name: "test", Resizer: NewContextResizer(&flakyResizer{})}); err != nil {
+		t.Fatal(err)
+	}
+	ctrl := NewResizeController("test", registry, fake.NewSimpleClientset(pv, pvc, sc), time.Hour, time.Minute).(*resizeController)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ctrl.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if total := testutil.ToFloat64(ctrl.metrics.pvcResizeTotal.WithLabelValues("test", "default", "sc", resultSuccess)); total != 1 {
+		t.Errorf("%v successful resizes are recorded, want 1", total)
+	}
+}
+
+// needsDetachResizer fails all resizes as the volume must be detached first.
+type needsDetachResizer struct{}
+
+func (needsDetachResizer) CanSupport(*v1.PersistentVolume) bool {
+	return true
+}
+
+func (needsDetachResizer) Resize(context.Context, *ResizeRequest) (resource.Quantity, bool, error) {
+	return resource.Quantity{}, false, NewResizeError(ErrorKindNeedsDetach, fmt.Errorf("volume is published"))
+}
+
+func TestNeedsDetachIsRetriedWithBackoff(t *testing.T) {
+	pv, pvc, sc := newResizeTestObjects()
+	kubeClient := fake.NewSimpleClientset(pv, pvc, sc)
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	registry := NewRegistry()
+	if err := registry.Register(Backend{Name: "test", Resizer: needsDetachResizer{}}); err != nil {
+		t.Fatal(err)
+	}
+	ctrl := NewResizeController("test", registry, kubeClient, time.Hour, time.Minute,
+		WithInformerFactory(informerFactory)).(*resizeController)
+	ctrl.resizeFunc = ctrl.resizePVC
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+
+	// No pod or volume attachment event follows, the PVC must be retried anyway.
+	b := ctrl.backends[0]
+	key := util.PVCKey(pvc)
+	b.queue().Add(key)
+	ctrl.syncPVCs(context.Background(), b)
+	if requeues := b.queue().NumRequeues(key); requeues != 1 {
+		t.Errorf("PVC is requeued %d times, want 1", requeues)
+	}
+}
+
+func TestSyncPVCSkipsTerminalFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		operationSize string
+		wantResized   bool
+	}{
+		{
+			name:          "request size unchanged since the failure",
+			operationSize: "2Gi",
+		},
+		{
+			name:          "request size changed since the failure",
+			operationSize: "3Gi",
+			wantResized:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pv, pvc, sc := newResizeTestObjects()
+			value, err := json.Marshal(&util.ResizeOperation{
+				ID:            "op",
+				TargetSize:    resource.MustParse(test.operationSize),
+				Backend:       "test",
+				FailureReason: string(ErrorKindTerminal),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			pv.Annotations = map[string]string{util.ResizeOperationAnnotation: string(value)}
+
+			kubeClient := fake.NewSimpleClientset(pv, pvc, sc)
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+			resizer := &shrinkableResizer{}
+			registry := NewRegistry()
+			if err := registry.Register(Backend{Name: "test", Resizer: NewContextResizer(resizer)}); err != nil {
+				t.Fatal(err)
+			}
+			ctrl := NewResizeController("test", registry, kubeClient, time.Hour, time.Minute,
+				WithInformerFactory(informerFactory)).(*resizeController)
+			ctrl.resizeFunc = ctrl.resizePVC
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			informerFactory.Start(stopCh)
+			informerFactory.WaitForCacheSync(stopCh)
+
+			if err := ctrl.syncPVC(context.Background(), ctrl.backends[0], util.PVCKey(pvc)); err != nil {
+				t.Fatalf("syncPVC failed: %v", err)
+			}
+			if resized := len(resizer.resizedSizes()) > 0; resized != test.wantResized {
+				t.Errorf("resized = %v, want %v", resized, test.wantResized)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/api/core/v1"
+)
+
+// dryRunResizePVC is the resizeFunc in dry run mode. It builds the resize request as resizePVC does,
+// then logs and records an event describing what would be done instead of resizing the volume
+// and updating the PV and PVC. As the PVC is never updated, it's processed again on every resync,
+// the same event is only recorded once in a while.
+func (ctrl *resizeController) dryRunResizePVC(
+	_ context.Context,
+	b *backend,
+	pvc *v1.PersistentVolumeClaim,
+	pv *v1.PersistentVolume) error {
+	key := util.PVCKey(pvc)
+	req, err := ctrl.newResizeRequest(pvc, pv)
+	if err != nil {
+		message := fmt.Sprintf("[dry run] resize volume %s failed: %v", pv.Name, err)
+		if b.dryRunEvents.shouldRecord(key, message) {
+			ctrl.pvcLogger(b, pvc).Error(err, "[dry run] build resize request failed")
+			b.eventRecorder.Event(pvc, v1.EventTypeWarning, util.VolumeResizeFailed, message)
+		}
+		return err
+	}
+
+	pvSize := pv.Spec.Capacity[v1.ResourceStorage]
+	next := "mark the resize of PVC finished"
+	if b.capabilities.FileSystemResize {
+		next = "mark PVC as file system resize pending if the backend requires it, otherwise mark the resize finished"
+	}
+	message := fmt.Sprintf("[dry run] would mark PVC as resizing, resize volume %s from %s to %s by backend %s "+
+		"with %d parameters and %d secrets, update capacity of PV %s, then %s",
+		pv.Name, pvSize.String(), req.RequestSize.String(), b.Name, len(req.Parameters), len(req.Secrets), pv.Name, next)
+	if !b.dryRunEvents.shouldRecord(key, message) {
+		return nil
+	}
+	ctrl.pvcLogger(b, pvc).Info(message)
+	b.eventRecorder.Event(pvc, v1.EventTypeNormal, util.VolumeResizeDryRun, message)
+	ctrl.metrics.recordDryRun(b.Name, pvc)
+	return nil
+}
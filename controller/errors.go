@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ResizeErrorKind classifies resize errors, the controller reacts to each kind differently.
+type ResizeErrorKind string
+
+const (
+	// ErrorKindTransient errors are retried with backoff, errors not returned by NewResizeError are transient.
+	ErrorKindTransient ResizeErrorKind = "transient"
+	// ErrorKindTerminal errors mean the volume can't be resized anymore, e.g. it's deleted from the backend.
+	// The resize isn't retried until the request size of the PVC is changed.
+	ErrorKindTerminal ResizeErrorKind = "terminal"
+	// ErrorKindInfeasible errors mean the request size can't be satisfied, e.g. it exceeds the max size
+	// supported by the backend. The resize isn't retried until the request size of the PVC is changed.
+	ErrorKindInfeasible ResizeErrorKind = "infeasible"
+	// ErrorKindQuotaExceeded errors mean the backend is out of capacity or quota, they are retried with backoff.
+	ErrorKindQuotaExceeded ResizeErrorKind = "quota_exceeded"
+	// ErrorKindNeedsDetach errors mean the volume must be detached before it can be resized.
+	// The resize is retried once pods using the volume are stopped or the volume is detached,
+	// and with backoff in case no such change is observed, e.g. the volume is already detached.
+	ErrorKindNeedsDetach ResizeErrorKind = "needs_detach"
+)
+
+// ResizeError is an error classified by kind, Resizers return it to tell the controller how to react.
+type ResizeError struct {
+	Kind ResizeErrorKind
+	Err  error
+}
+
+// NewResizeError creates a ResizeError of kind.
+func NewResizeError(kind ResizeErrorKind, err error) error {
+	return &ResizeError{Kind: kind, Err: err}
+}
+
+func (e *ResizeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ResizeError) Unwrap() error {
+	return e.Err
+}
+
+// GetErrorKind returns the kind of err, ErrorKindTransient if err isn't a ResizeError.
+func GetErrorKind(err error) ResizeErrorKind {
+	var resizeErr *ResizeError
+	if errors.As(err, &resizeErr) {
+		return resizeErr.Kind
+	}
+	return ErrorKindTransient
+}
+
+// isRetryable returns true if err should be retried with backoff.
+func isRetryable(err error) bool {
+	switch GetErrorKind(err) {
+	case ErrorKindTransient, ErrorKindQuotaExceeded, ErrorKindNeedsDetach:
+		return true
+	default:
+		return false
+	}
+}
+
+// wrapResizeError adds the message to err and keeps its kind.
+func wrapResizeError(err error, format string, args ...interface{}) error {
+	return &ResizeError{
+		Kind: GetErrorKind(err),
+		Err:  fmt.Errorf("%s: %v", fmt.Sprintf(format, args...), err),
+	}
+}
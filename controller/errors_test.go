@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestGetErrorKind(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantKind      ResizeErrorKind
+		wantRetryable bool
+	}{
+		{
+			name:          "unclassified error",
+			err:           errors.New("timeout"),
+			wantKind:      ErrorKindTransient,
+			wantRetryable: true,
+		},
+		{
+			name:          "quota exceeded",
+			err:           NewResizeError(ErrorKindQuotaExceeded, errors.New("out of capacity")),
+			wantKind:      ErrorKindQuotaExceeded,
+			wantRetryable: true,
+		},
+		{
+			name:          "needs detach",
+			err:           NewResizeError(ErrorKindNeedsDetach, errors.New("volume is published")),
+			wantKind:      ErrorKindNeedsDetach,
+			wantRetryable: true,
+		},
+		{
+			name:     "infeasible",
+			err:      NewResizeError(ErrorKindInfeasible, errors.New("too large")),
+			wantKind: ErrorKindInfeasible,
+		},
+		{
+			name:     "terminal wrapped by fmt.Errorf",
+			err:      fmt.Errorf("resize failed: %w", NewResizeError(ErrorKindTerminal, errors.New("volume not found"))),
+			wantKind: ErrorKindTerminal,
+		},
+		{
+			name:     "terminal wrapped by wrapResizeError",
+			err:      wrapResizeError(NewResizeError(ErrorKindTerminal, errors.New("volume not found")), "resize volume %s failed", "pv"),
+			wantKind: ErrorKindTerminal,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if kind := GetErrorKind(test.err); kind != test.wantKind {
+				t.Errorf("GetErrorKind() = %s, want %s", kind, test.wantKind)
+			}
+			if retryable := isRetryable(test.err); retryable != test.wantRetryable {
+				t.Errorf("isRetryable() = %v, want %v", retryable, test.wantRetryable)
+			}
+		})
+	}
+}
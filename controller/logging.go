@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"github.com/mlmhl/external-resizer/logging"
+	"github.com/mlmhl/external-resizer/util"
+
+	"github.com/go-logr/logr"
+	"k8s.io/api/core/v1"
+)
+
+// pvcLogger returns a logger with fields identifying pvc, its volume, its StorageClass
+// and the backend processing it, b can be nil if the backend is unknown.
+func (ctrl *resizeController) pvcLogger(b *backend, pvc *v1.PersistentVolumeClaim) logr.Logger {
+	keysAndValues := []interface{}{logging.KeyPVC, util.PVCKey(pvc)}
+	if pvc.Spec.VolumeName != "" {
+		keysAndValues = append(keysAndValues, logging.KeyPV, pvc.Spec.VolumeName)
+	}
+	if scName := util.GetPVCStorageClass(pvc); scName != "" {
+		keysAndValues = append(keysAndValues, logging.KeyStorageClass, scName)
+	}
+	if b != nil {
+		keysAndValues = append(keysAndValues, logging.KeyBackend, b.Name)
+	}
+	return ctrl.logger.WithValues(keysAndValues...)
+}
@@ -1,70 +1,196 @@
 package controller
 
 import (
-	"net/http"
+	"context"
 	"time"
 
 	"github.com/mlmhl/external-resizer/util"
 
-	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
 	subsystem         = "resize_controller" // Prometheus subsystem name for resize controller.
 	namespaceLabel    = "namespace"         // Prometheus label name for k8s namespace.
 	storageClassLabel = "storage_class"     // Prometheus label name for k8s storage class.
+	capabilityLabel   = "capability"        // Prometheus label name for resizer capability.
+	backendLabel      = "backend"           // Prometheus label name for resizer backend.
+	reasonLabel       = "reason"            // Prometheus label name for resize error kind.
+	resultLabel       = "result"            // Prometheus label name for operation result, success or failure.
+	operationLabel    = "operation"         // Prometheus label name for API operation.
+	queueNameLabel    = "name"              // Prometheus label name for work queue name.
+
+	resultSuccess = "success"
+	resultFailure = "failure"
 )
 
-var (
+// metrics holds metrics of a resize controller. They are registered on a registry of the controller,
+// so that controllers in one process don't share metrics, and metrics don't collide with those
+// registered on the global registry by the embedding application.
+type metrics struct {
+	registry *prometheus.Registry
+
 	// pvcResizeTotal is used to collect accumulated count of persistent volume claims resized.
-	pvcResizeTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Subsystem: subsystem,
-			Name:      "pvc_resize_total",
-			Help:      "Total number of persistent volume claims resized, broken down by namespace and storage class name.",
-		}, []string{namespaceLabel, storageClassLabel})
+	pvcResizeTotal *prometheus.CounterVec
 	// pvcResizeFailed is used to collect accumulated count of persistent volume claim resize failed attempts.
-	pvcResizeFailed = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Subsystem: subsystem,
-			Name:      "pvc_resize_failed",
-			Help:      "Total number of persistent volume claim resize failed attempts, broken down by namespace and storage class name.",
-		}, []string{namespaceLabel, storageClassLabel})
-	pvcResizeDurationSeconds = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Subsystem: subsystem,
-			Name:      "pvc_resize_duration_seconds",
-			Help:      "Latency in seconds to resize persistent volume claims. Broken down by namespace and storage class name.",
-		}, []string{namespaceLabel, storageClassLabel})
-)
+	pvcResizeFailed          *prometheus.CounterVec
+	pvcResizeDurationSeconds *prometheus.HistogramVec
+	// resizeInFlight is the number of resize operations in progress.
+	resizeInFlight *prometheus.GaugeVec
+	// backendResizeDurationSeconds is the latency of Resizer.Resize calls only, excluding API requests.
+	backendResizeDurationSeconds *prometheus.HistogramVec
+	// apiRequestDurationSeconds is the latency of API requests updating PVs and PVCs.
+	apiRequestDurationSeconds *prometheus.HistogramVec
+	// bytesAdded is used to collect accumulated bytes added to volumes by expansion.
+	bytesAdded *prometheus.CounterVec
+	// pvcResizeDryRun is used to collect accumulated count of resizes skipped in dry run mode.
+	pvcResizeDryRun *prometheus.CounterVec
+	// resizerCapabilities is set to 1 for each capability the resizer supports, 0 otherwise.
+	resizerCapabilities *prometheus.GaugeVec
 
-type MetricConfig struct {
-	Path    string
-	Address string
+	queue *workqueueMetrics
 }
 
-func startMetricsServer(config *MetricConfig) {
-	prometheus.MustRegister(pvcResizeTotal, pvcResizeFailed, pvcResizeDurationSeconds)
-	http.Handle(config.Path, promhttp.Handler())
-	err := http.ListenAndServe(config.Address, nil)
-	if err != nil {
-		glog.Fatalf("Failed to start metrics server : %v", err)
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		pvcResizeTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: subsystem,
+				Name:      "pvc_resize_total",
+				Help:      "Total number of persistent volume claim resize attempts, broken down by backend, namespace, storage class name and result.",
+			}, []string{backendLabel, namespaceLabel, storageClassLabel, resultLabel}),
+		pvcResizeFailed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: subsystem,
+				Name:      "pvc_resize_failed",
+				Help:      "Total number of persistent volume claim resize failed attempts, broken down by backend, namespace, storage class name and reason.",
+			}, []string{backendLabel, namespaceLabel, storageClassLabel, reasonLabel}),
+		pvcResizeDurationSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Subsystem: subsystem,
+				Name:      "pvc_resize_duration_seconds",
+				Help:      "Latency in seconds to resize persistent volume claims. Broken down by backend, namespace, storage class name and result.",
+			}, []string{backendLabel, namespaceLabel, storageClassLabel, resultLabel}),
+		resizeInFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Subsystem: subsystem,
+				Name:      "resize_in_flight",
+				Help:      "Number of persistent volume claim resize operations in progress, broken down by backend.",
+			}, []string{backendLabel}),
+		backendResizeDurationSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Subsystem: subsystem,
+				Name:      "backend_resize_duration_seconds",
+				Help:      "Latency in seconds of resize calls to backends, broken down by backend and result.",
+				Buckets:   prometheus.ExponentialBuckets(0.05, 2, 14),
+			}, []string{backendLabel, resultLabel}),
+		apiRequestDurationSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Subsystem: subsystem,
+				Name:      "api_request_duration_seconds",
+				Help:      "Latency in seconds of API requests updating PVs and PVCs, broken down by operation and result.",
+			}, []string{operationLabel, resultLabel}),
+		bytesAdded: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: subsystem,
+				Name:      "bytes_added_total",
+				Help:      "Total number of bytes added to volumes by expansion, broken down by backend, namespace and storage class name.",
+			}, []string{backendLabel, namespaceLabel, storageClassLabel}),
+		pvcResizeDryRun: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: subsystem,
+				Name:      "pvc_resize_dry_run_total",
+				Help:      "Total number of persistent volume claim resizes skipped in dry run mode, broken down by backend, namespace and storage class name.",
+			}, []string{backendLabel, namespaceLabel, storageClassLabel}),
+		resizerCapabilities: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Subsystem: subsystem,
+				Name:      "resizer_capabilities",
+				Help:      "Capabilities of resizers, 1 if supported and 0 if not, broken down by backend and capability name.",
+			}, []string{backendLabel, capabilityLabel}),
+		queue: newWorkqueueMetrics(),
 	}
+	m.registry.MustRegister(
+		m.pvcResizeTotal,
+		m.pvcResizeFailed,
+		m.pvcResizeDurationSeconds,
+		m.pvcResizeDryRun,
+		m.resizeInFlight,
+		m.backendResizeDurationSeconds,
+		m.apiRequestDurationSeconds,
+		m.bytesAdded,
+		m.resizerCapabilities,
+	)
+	m.queue.register(m.registry)
+	return m
+}
+
+func (ctrl *resizeController) MetricsRegistry() *prometheus.Registry {
+	return ctrl.metrics.registry
 }
 
-func resizeFuncWithMetrics(resizeFunc resizeFunc) resizeFunc {
-	return func(pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) error {
+func (m *metrics) resizeFuncWithMetrics(resizeFunc resizeFunc) resizeFunc {
+	return func(ctx context.Context, b *backend, pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) error {
+		inFlight := m.resizeInFlight.WithLabelValues(b.Name)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
 		startTime := time.Now()
-		err := resizeFunc(pvc, pv)
+		err := resizeFunc(ctx, b, pvc, pv)
+		scName := util.GetPVCStorageClass(pvc)
 		if err != nil {
-			pvcResizeFailed.WithLabelValues(pvc.Namespace, util.GetPVCStorageClass(pvc)).Inc()
+			m.pvcResizeFailed.WithLabelValues(b.Name, pvc.Namespace, scName, string(GetErrorKind(err))).Inc()
 		}
-		pvcResizeTotal.WithLabelValues(pvc.Namespace, util.GetPVCStorageClass(pvc)).Inc()
-		pvcResizeDurationSeconds.WithLabelValues(pvc.Namespace, util.GetPVCStorageClass(pvc)).
+		m.pvcResizeTotal.WithLabelValues(b.Name, pvc.Namespace, scName, result(err)).Inc()
+		m.pvcResizeDurationSeconds.WithLabelValues(b.Name, pvc.Namespace, scName, result(err)).
 			Observe(time.Since(startTime).Seconds())
 		return err
 	}
 }
+
+// observeBackendResize records the latency of a Resizer.Resize call started at startTime.
+func (m *metrics) observeBackendResize(backend string, startTime time.Time, err error) {
+	m.backendResizeDurationSeconds.WithLabelValues(backend, result(err)).Observe(time.Since(startTime).Seconds())
+}
+
+// observeAPIRequest records the latency of an API request started at startTime.
+func (m *metrics) observeAPIRequest(operation string, startTime time.Time, err error) {
+	m.apiRequestDurationSeconds.WithLabelValues(operation, result(err)).Observe(time.Since(startTime).Seconds())
+}
+
+// recordBytesAdded records the bytes added to the volume of pvc if it is expanded.
+func (m *metrics) recordBytesAdded(backend string, pvc *v1.PersistentVolumeClaim, oldSize, newSize resource.Quantity) {
+	if added := newSize.Value() - oldSize.Value(); added > 0 {
+		m.bytesAdded.WithLabelValues(backend, pvc.Namespace, util.GetPVCStorageClass(pvc)).Add(float64(added))
+	}
+}
+
+// recordDryRun records a resize of pvc skipped in dry run mode.
+func (m *metrics) recordDryRun(backend string, pvc *v1.PersistentVolumeClaim) {
+	m.pvcResizeDryRun.WithLabelValues(backend, pvc.Namespace, util.GetPVCStorageClass(pvc)).Inc()
+}
+
+func result(err error) string {
+	if err != nil {
+		return resultFailure
+	}
+	return resultSuccess
+}
+
+func (m *metrics) recordCapabilities(backend string, capabilities Capabilities) {
+	for name, supported := range map[string]bool{
+		"online_expansion":   capabilities.OnlineExpansion,
+		"offline_expansion":  capabilities.OfflineExpansion,
+		"shrink":             capabilities.Shrink,
+		"file_system_resize": capabilities.FileSystemResize,
+	} {
+		value := 0.0
+		if supported {
+			value = 1
+		}
+		m.resizerCapabilities.WithLabelValues(backend, name).Set(value)
+	}
+}
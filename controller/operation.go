@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/mlmhl/external-resizer/logging"
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// startResizeOperation records a new resize operation on the PV of req before the backend is called,
+// or resumes the operation recorded by a previous attempt with the same backend and target size.
+func (ctrl *resizeController) startResizeOperation(ctx context.Context, b *backend, req *ResizeRequest) error {
+	logger := logging.FromContext(ctx)
+	op, err := util.GetResizeOperation(req.PV)
+	if err != nil {
+		logger.Error(err, "Ignore the recorded resize operation and start a new one")
+		op = nil
+	}
+	if op != nil && op.Backend == b.Name && op.TargetSize.Cmp(req.RequestSize) == 0 {
+		logger.Info("Resuming resize operation", "operation", op.ID,
+			"targetSize", op.TargetSize.String(), "startTime", op.StartTime.Format(time.RFC3339))
+		req.Operation, req.Resumed = op, true
+		return nil
+	}
+
+	op = &util.ResizeOperation{
+		ID:         string(uuid.NewUUID()),
+		TargetSize: req.RequestSize,
+		StartTime:  time.Now().UTC(),
+		Backend:    b.Name,
+	}
+	startTime := time.Now()
+	pv, err := util.SetResizeOperation(req.PV, op, ctrl.kubeClient)
+	ctrl.metrics.observeAPIRequest("set_resize_operation", startTime, err)
+	if err != nil {
+		return err
+	}
+	logger.V(4).Info("Started resize operation", "operation", op.ID, "targetSize", op.TargetSize.String())
+	req.PV, req.Operation = pv, op
+	return nil
+}
+
+// reconcileResizeOperations checks resize operations recorded on PVs, which were interrupted
+// as the resizer stopped before they finished. Operations whose PVC still requests the target size
+// are resumed when the PVC is processed, others are obsolete and removed.
+func (ctrl *resizeController) reconcileResizeOperations() {
+	pvs, err := ctrl.pvLister.List(labels.Everything())
+	if err != nil {
+		ctrl.logger.Error(err, "List PVs failed")
+		return
+	}
+	for _, pv := range pvs {
+		logger := ctrl.logger.WithValues(logging.KeyPV, pv.Name)
+		op, err := util.GetResizeOperation(pv)
+		if err != nil {
+			logger.Error(err, "Ignore the recorded resize operation")
+			continue
+		}
+		if op == nil {
+			continue
+		}
+		logger = logger.WithValues("operation", op.ID, "targetSize", op.TargetSize.String())
+		if ctrl.resizeOperationPending(pv, op) {
+			logger.Info("Found interrupted resize operation, it will be resumed")
+			continue
+		}
+		if ctrl.dryRun {
+			logger.Info("[dry run] would remove obsolete resize operation")
+			continue
+		}
+		logger.Info("Removing obsolete resize operation")
+		if err := util.ClearResizeOperation(pv, ctrl.kubeClient); err != nil {
+			logger.Error(err, "Remove resize operation failed")
+		}
+	}
+}
+
+// resizeOperationPending returns true if the PVC bound to pv still requests to resize the volume to the target size of op.
+func (ctrl *resizeController) resizeOperationPending(pv *v1.PersistentVolume, op *util.ResizeOperation) bool {
+	claimRef := pv.Spec.ClaimRef
+	if claimRef == nil {
+		return false
+	}
+	pvc, err := ctrl.pvcLister.PersistentVolumeClaims(claimRef.Namespace).Get(claimRef.Name)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			// Keep the operation as we can't tell whether it is obsolete.
+			ctrl.logger.Error(err, "Get PVC failed", logging.KeyPVC, claimRef.Namespace+"/"+claimRef.Name)
+			return true
+		}
+		return false
+	}
+	requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	return (ctrl.pvcNeedResize(pvc) || ctrl.pvcShrinking(pvc, pv)) && requestSize.Cmp(op.TargetSize) == 0
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newOperationTestController returns a controller whose caches are synced with objects.
+func newOperationTestController(t *testing.T, objects ...runtime.Object) (*resizeController, *fake.Clientset, func()) {
+	kubeClient := fake.NewSimpleClientset(objects...)
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	registry := NewRegistry()
+	if err := registry.Register(Backend{Name: "test", Resizer: NewContextResizer(&failingResizer{})}); err != nil {
+		t.Fatal(err)
+	}
+	ctrl := NewResizeController("test", registry, kubeClient, time.Hour, time.Minute,
+		WithInformerFactory(informerFactory)).(*resizeController)
+	stopCh := make(chan struct{})
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+	return ctrl, kubeClient, func() { close(stopCh) }
+}
+
+func setTestResizeOperation(t *testing.T, pv *v1.PersistentVolume, op *util.ResizeOperation) {
+	kubeClient := fake.NewSimpleClientset(pv)
+	updatedPV, err := util.SetResizeOperation(pv, op, kubeClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pv.Annotations = updatedPV.Annotations
+}
+
+func TestStartResizeOperation(t *testing.T) {
+	tests := []struct {
+		name        string
+		backend     string
+		targetSize  string
+		wantResumed bool
+	}{
+		{
+			name:        "same backend and target size",
+			backend:     "test",
+			targetSize:  "2Gi",
+			wantResumed: true,
+		},
+		{
+			name:       "another target size",
+			backend:    "test",
+			targetSize: "3Gi",
+		},
+		{
+			name:       "another backend",
+			backend:    "other",
+			targetSize: "2Gi",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pv, pvc, sc := newResizeTestObjects()
+			recorded := &util.ResizeOperation{
+				ID:         "recorded",
+				TargetSize: resource.MustParse(test.targetSize),
+				StartTime:  time.Now().Add(-time.Hour).UTC(),
+				Backend:    test.backend,
+			}
+			setTestResizeOperation(t, pv, recorded)
+			ctrl, kubeClient, stop := newOperationTestController(t, pv, pvc, sc)
+			defer stop()
+
+			req := &ResizeRequest{PV: pv, PVC: pvc, RequestSize: pvc.Spec.Resources.Requests[v1.ResourceStorage]}
+			if err := ctrl.startResizeOperation(context.Background(), ctrl.backends[0], req); err != nil {
+				t.Fatalf("startResizeOperation failed: %v", err)
+			}
+			if req.Resumed != test.wantResumed {
+				t.Errorf("resumed = %v, want %v", req.Resumed, test.wantResumed)
+			}
+			if resumedID := req.Operation.ID == recorded.ID; resumedID != test.wantResumed {
+				t.Errorf("operation ID = %s, recorded operation ID = %s", req.Operation.ID, recorded.ID)
+			}
+
+			// The operation in progress is recorded on the PV.
+			updatedPV, err := kubeClient.CoreV1().PersistentVolumes().Get(pv.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			op, err := util.GetResizeOperation(updatedPV)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if op == nil || op.ID != req.Operation.ID || op.Backend != "test" || op.TargetSize.Cmp(req.RequestSize) != 0 {
+				t.Errorf("recorded operation = %+v, want %+v", op, req.Operation)
+			}
+		})
+	}
+}
+
+func TestReconcileResizeOperations(t *testing.T) {
+	tests := []struct {
+		name string
+		// requestSize is the request size of the PVC bound to the PV, empty if the PVC is deleted.
+		requestSize string
+		wantKept    bool
+	}{
+		{
+			name:        "PVC still requests the target size",
+			requestSize: "2Gi",
+			wantKept:    true,
+		},
+		{
+			name:        "request size changed",
+			requestSize: "3Gi",
+		},
+		{
+			name:        "request size reverted",
+			requestSize: "1Gi",
+		},
+		{
+			name: "PVC deleted",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pv, pvc, sc := newResizeTestObjects()
+			setTestResizeOperation(t, pv, &util.ResizeOperation{
+				ID:         "interrupted",
+				TargetSize: resource.MustParse("2Gi"),
+				Backend:    "test",
+			})
+			objects := []runtime.Object{pv, sc}
+			if test.requestSize != "" {
+				pvc.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse(test.requestSize)
+				objects = append(objects, pvc)
+			}
+			ctrl, kubeClient, stop := newOperationTestController(t, objects...)
+			defer stop()
+
+			ctrl.reconcileResizeOperations()
+
+			updatedPV, err := kubeClient.CoreV1().PersistentVolumes().Get(pv.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, kept := updatedPV.Annotations[util.ResizeOperationAnnotation]; kept != test.wantKept {
+				t.Errorf("operation kept = %v, want %v", kept, test.wantKept)
+			}
+		})
+	}
+}
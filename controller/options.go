@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/mlmhl/external-resizer/client/clientset/versioned"
+	"github.com/mlmhl/external-resizer/util"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/informers"
+)
+
+// Option configures optional behaviors of the resize controller.
+type Option func(*resizeController)
+
+// WithWorkers sets the number of workers processing PVCs of each backend, DefaultWorkers is used if not set.
+func WithWorkers(workers int) Option {
+	return func(ctrl *resizeController) {
+		ctrl.threadiness = workers
+	}
+}
+
+// WithRateLimit sets the overall rate limit of each backend, DefaultRateLimitConfig is used if not set.
+func WithRateLimit(config RateLimitConfig) Option {
+	return func(ctrl *resizeController) {
+		ctrl.rateLimitConfig = config
+	}
+}
+
+// WithStorageClassResizeTimeouts overrides the resize timeout for PVCs of the StorageClasses, 0 means no timeout.
+func WithStorageClassResizeTimeouts(timeouts map[string]time.Duration) Option {
+	return func(ctrl *resizeController) {
+		ctrl.scResizeTimeouts = timeouts
+	}
+}
+
+// WithMetrics serves metrics by the metrics server configured by config, see MetricConfig for
+// other endpoints of the server. Metrics are recorded but not served if not set, see MetricsRegistry.
+func WithMetrics(config *MetricConfig) Option {
+	return func(ctrl *resizeController) {
+		ctrl.metricConfig = config
+	}
+}
+
+// WithLeaderElection runs the controller only while it is the leader elected by config.
+// Leader election is disabled if not set.
+func WithLeaderElection(config *util.LeaderElectionConfig) Option {
+	return func(ctrl *resizeController) {
+		ctrl.leaderElectionConfig = config
+	}
+}
+
+// WithShutdownGracePeriod sets how long in-flight resize operations may keep running after the controller
+// is stopped or loses leadership, before they are cancelled. Defaults to 0, which cancels them immediately.
+// It should be shorter than the leader election lease duration, otherwise the new leader may resize
+// the same volume concurrently.
+func WithShutdownGracePeriod(gracePeriod time.Duration) Option {
+	return func(ctrl *resizeController) {
+		ctrl.shutdownGracePeriod = gracePeriod
+	}
+}
+
+// WithLeaderTask adds a task which runs alongside the workers while the controller is running,
+// i.e. only while we are the leader if leader election is enabled. The context passed to task
+// is cancelled when the controller stops running, task should return then.
+func WithLeaderTask(task func(ctx context.Context)) Option {
+	return func(ctrl *resizeController) {
+		ctrl.leaderTasks = append(ctrl.leaderTasks, task)
+	}
+}
+
+// WithInformerFactory sets the factory of informers used by the controller, so that their caches can be
+// shared with other components, e.g. leader tasks. The controller starts the factory when it runs.
+// A factory created from the kube client and the resync period is used if not set.
+func WithInformerFactory(factory informers.SharedInformerFactory) Option {
+	return func(ctrl *resizeController) {
+		ctrl.informerFactory = factory
+	}
+}
+
+// WithResizePolicies enforces ResizePolicies fetched by client on resize requests.
+// The ResizePolicy CRD must be installed in the cluster.
+func WithResizePolicies(client versioned.Interface) Option {
+	return func(ctrl *resizeController) {
+		ctrl.policyClient = client
+	}
+}
+
+// WithDryRun runs the controller in dry run mode, in which resize requests are processed as usual,
+// but instead of resizing volumes and updating PVs and PVCs, the controller only logs and records
+// events describing what it would do. Events are still written to the API server.
+func WithDryRun() Option {
+	return func(ctrl *resizeController) {
+		ctrl.dryRun = true
+	}
+}
+
+// WithBackoff sets how failed resize operations are retried, DefaultBackoffConfig is used if not set.
+func WithBackoff(config BackoffConfig) Option {
+	return func(ctrl *resizeController) {
+		ctrl.backoffConfig = config
+	}
+}
+
+// WithTracerProvider sets the provider of spans traced by the controller, the global provider is used if not set.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(ctrl *resizeController) {
+		ctrl.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// WithLogger sets the logger of the controller, logging.Logger() is used if not set.
+// Logs about a PVC carry the fields defined in the logging package, and the logger
+// with these fields is passed to Resizers in the context, see logging.FromContext.
+func WithLogger(logger logr.Logger) Option {
+	return func(ctrl *resizeController) {
+		ctrl.logger = logger
+	}
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	resizev1alpha1 "github.com/mlmhl/external-resizer/apis/resize/v1alpha1"
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PolicyViolation describes why a resize request violates a ResizePolicy.
+type PolicyViolation struct {
+	// Policy is the name of the violated ResizePolicy.
+	Policy  string
+	Message string
+	// RetryAfter is set if the request will be allowed after this duration, e.g. min interval isn't satisfied yet.
+	RetryAfter time.Duration
+}
+
+// CheckResizePolicies checks the request size of pvc against policies of its namespace,
+// returns a violation or nil if all policies are satisfied. All policies are checked, a violation
+// without RetryAfter is preferred as the request can't be allowed by waiting, otherwise the violation
+// with the longest RetryAfter is returned, after which all policies are satisfied.
+// lastResizeTime is when the volume of pvc was resized last time, zero if never or unknown.
+// Only expansion is limited by MaxIncrement and MinInterval, a shrink request is checked by MaxSize
+// and StorageClassNames only.
+func CheckResizePolicies(
+	policies []*resizev1alpha1.ResizePolicy,
+	pvc *v1.PersistentVolumeClaim,
+	lastResizeTime time.Time,
+	now time.Time) *PolicyViolation {
+	var result *PolicyViolation
+	for _, policy := range policies {
+		violation := checkResizePolicy(policy, pvc, lastResizeTime, now)
+		if violation == nil {
+			continue
+		}
+		if violation.RetryAfter == 0 {
+			return violation
+		}
+		if result == nil || violation.RetryAfter > result.RetryAfter {
+			result = violation
+		}
+	}
+	return result
+}
+
+// checkResizePolicy checks the request size of pvc against the policy, returns the first violation found
+// or nil if the policy is satisfied.
+func checkResizePolicy(
+	policy *resizev1alpha1.ResizePolicy,
+	pvc *v1.PersistentVolumeClaim,
+	lastResizeTime time.Time,
+	now time.Time) *PolicyViolation {
+	requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	actualSize := pvc.Status.Capacity[v1.ResourceStorage]
+	expanding := requestSize.Cmp(actualSize) > 0
+	scName := util.GetPVCStorageClass(pvc)
+	spec := policy.Spec
+
+	if len(spec.StorageClassNames) > 0 && !containsString(spec.StorageClassNames, scName) {
+		return &PolicyViolation{
+			Policy:  policy.Name,
+			Message: fmt.Sprintf("ResizePolicy %s doesn't allow resizing PVCs of StorageClass %q", policy.Name, scName),
+		}
+	}
+
+	if spec.MaxSize != nil && requestSize.Cmp(*spec.MaxSize) > 0 {
+		return &PolicyViolation{
+			Policy: policy.Name,
+			Message: fmt.Sprintf("Request size %s exceeds max size %s of ResizePolicy %s",
+				requestSize.String(), spec.MaxSize.String(), policy.Name),
+		}
+	}
+
+	if !expanding {
+		return nil
+	}
+
+	if spec.MaxIncrement != nil {
+		increment := requestSize.DeepCopy()
+		increment.Sub(actualSize)
+		if increment.Cmp(*spec.MaxIncrement) > 0 {
+			return &PolicyViolation{
+				Policy: policy.Name,
+				Message: fmt.Sprintf("Expanding from %s to %s exceeds max increment %s of ResizePolicy %s",
+					actualSize.String(), requestSize.String(), spec.MaxIncrement.String(), policy.Name),
+			}
+		}
+	}
+
+	if spec.MinInterval != nil && !lastResizeTime.IsZero() {
+		nextResizeTime := lastResizeTime.Add(spec.MinInterval.Duration)
+		if now.Before(nextResizeTime) {
+			return &PolicyViolation{
+				Policy: policy.Name,
+				Message: fmt.Sprintf("Volume was resized at %s, ResizePolicy %s requires at least %s between resizes, "+
+					"the expansion will be retried at %s", lastResizeTime.Format(time.RFC3339),
+					policy.Name, spec.MinInterval.Duration, nextResizeTime.Format(time.RFC3339)),
+				RetryAfter: nextResizeTime.Sub(now),
+			}
+		}
+	}
+
+	return nil
+}
+
+// addResizePolicy requeues PVCs in the namespace of the policy, as they may be refused by a previous policy.
+func (ctrl *resizeController) addResizePolicy(obj interface{}) {
+	policy, ok := obj.(*resizev1alpha1.ResizePolicy)
+	if !ok {
+		return
+	}
+	pvcs, err := ctrl.pvcLister.PersistentVolumeClaims(policy.Namespace).List(labels.Everything())
+	if err != nil {
+		ctrl.logger.Error(err, "List PVCs failed", "namespace", policy.Namespace)
+		return
+	}
+	for _, pvc := range pvcs {
+		ctrl.enqueuePVC(pvc)
+	}
+}
+
+func (ctrl *resizeController) updateResizePolicy(oldObj, newObj interface{}) {
+	oldPolicy, ok := oldObj.(*resizev1alpha1.ResizePolicy)
+	if !ok {
+		return
+	}
+	newPolicy, ok := newObj.(*resizev1alpha1.ResizePolicy)
+	if !ok {
+		return
+	}
+	if oldPolicy.ResourceVersion == newPolicy.ResourceVersion {
+		// Periodic resync, PVCs will be resynced by their own informer.
+		return
+	}
+	ctrl.addResizePolicy(newPolicy)
+}
+
+func (ctrl *resizeController) deleteResizePolicy(obj interface{}) {
+	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok && unknown.Obj != nil {
+		obj = unknown.Obj
+	}
+	ctrl.addResizePolicy(obj)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	resizev1alpha1 "github.com/mlmhl/external-resizer/apis/resize/v1alpha1"
+	resizelisters "github.com/mlmhl/external-resizer/client/listers/resize/v1alpha1"
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newResizePolicy(name string, spec resizev1alpha1.ResizePolicySpec) *resizev1alpha1.ResizePolicy {
+	return &resizev1alpha1.ResizePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       spec,
+	}
+}
+
+func quantityPtr(value string) *resource.Quantity {
+	q := resource.MustParse(value)
+	return &q
+}
+
+func TestCheckResizePoliciesPrefersViolationsWithoutRetry(t *testing.T) {
+	_, pvc, _ := newResizeTestObjects()
+	now := time.Now()
+	lastResizeTime := now.Add(-time.Minute)
+	minInterval := newResizePolicy("min-interval", resizev1alpha1.ResizePolicySpec{
+		MinInterval: &metav1.Duration{Duration: time.Hour},
+	})
+	longerMinInterval := newResizePolicy("longer-min-interval", resizev1alpha1.ResizePolicySpec{
+		MinInterval: &metav1.Duration{Duration: 2 * time.Hour},
+	})
+	maxSize := newResizePolicy("max-size", resizev1alpha1.ResizePolicySpec{MaxSize: quantityPtr("1Gi")})
+
+	// The request can never be allowed due to max-size, waiting for min-interval doesn't help.
+	violation := CheckResizePolicies([]*resizev1alpha1.ResizePolicy{minInterval, maxSize}, pvc, lastResizeTime, now)
+	if violation == nil || violation.Policy != maxSize.Name || violation.RetryAfter != 0 {
+		t.Errorf("violation = %+v, want violation of %s without retry", violation, maxSize.Name)
+	}
+
+	// The request is allowed only after all intervals elapse.
+	violation = CheckResizePolicies([]*resizev1alpha1.ResizePolicy{minInterval, longerMinInterval}, pvc, lastResizeTime, now)
+	if violation == nil || violation.Policy != longerMinInterval.Name {
+		t.Fatalf("violation = %+v, want violation of %s", violation, longerMinInterval.Name)
+	}
+	if want := 2*time.Hour - time.Minute; violation.RetryAfter != want {
+		t.Errorf("retry after %v, want %v", violation.RetryAfter, want)
+	}
+}
+
+func TestCheckResizePolicies(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name           string
+		spec           resizev1alpha1.ResizePolicySpec
+		requestSize    string
+		lastResizeTime time.Time
+		wantViolation  bool
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:        "no limit",
+			requestSize: "100Gi",
+		},
+		{
+			name:        "max size satisfied",
+			spec:        resizev1alpha1.ResizePolicySpec{MaxSize: quantityPtr("10Gi")},
+			requestSize: "10Gi",
+		},
+		{
+			name:          "max size exceeded",
+			spec:          resizev1alpha1.ResizePolicySpec{MaxSize: quantityPtr("10Gi")},
+			requestSize:   "11Gi",
+			wantViolation: true,
+		},
+		{
+			name:        "max increment satisfied",
+			spec:        resizev1alpha1.ResizePolicySpec{MaxIncrement: quantityPtr("1Gi")},
+			requestSize: "2Gi",
+		},
+		{
+			name:          "max increment exceeded",
+			spec:          resizev1alpha1.ResizePolicySpec{MaxIncrement: quantityPtr("1Gi")},
+			requestSize:   "3Gi",
+			wantViolation: true,
+		},
+		{
+			name:        "max increment doesn't limit shrink",
+			spec:        resizev1alpha1.ResizePolicySpec{MaxIncrement: quantityPtr("1Mi")},
+			requestSize: "512Mi",
+		},
+		{
+			name:           "min interval elapsed",
+			spec:           resizev1alpha1.ResizePolicySpec{MinInterval: &metav1.Duration{Duration: time.Hour}},
+			requestSize:    "2Gi",
+			lastResizeTime: now.Add(-2 * time.Hour),
+		},
+		{
+			name:           "min interval not elapsed",
+			spec:           resizev1alpha1.ResizePolicySpec{MinInterval: &metav1.Duration{Duration: time.Hour}},
+			requestSize:    "2Gi",
+			lastResizeTime: now.Add(-time.Minute),
+			wantViolation:  true,
+			wantRetryAfter: 59 * time.Minute,
+		},
+		{
+			name:        "min interval of a volume never resized",
+			spec:        resizev1alpha1.ResizePolicySpec{MinInterval: &metav1.Duration{Duration: time.Hour}},
+			requestSize: "2Gi",
+		},
+		{
+			name:           "min interval doesn't limit shrink",
+			spec:           resizev1alpha1.ResizePolicySpec{MinInterval: &metav1.Duration{Duration: time.Hour}},
+			requestSize:    "512Mi",
+			lastResizeTime: now.Add(-time.Minute),
+		},
+		{
+			name:        "StorageClass allowed",
+			spec:        resizev1alpha1.ResizePolicySpec{StorageClassNames: []string{"fast", "sc"}},
+			requestSize: "2Gi",
+		},
+		{
+			name:          "StorageClass not allowed",
+			spec:          resizev1alpha1.ResizePolicySpec{StorageClassNames: []string{"fast"}},
+			requestSize:   "2Gi",
+			wantViolation: true,
+		},
+		{
+			name:          "StorageClass not allowed to shrink",
+			spec:          resizev1alpha1.ResizePolicySpec{StorageClassNames: []string{"fast"}},
+			requestSize:   "512Mi",
+			wantViolation: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, pvc, _ := newResizeTestObjects()
+			pvc.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse(test.requestSize)
+			policy := newResizePolicy("policy", test.spec)
+
+			violation := CheckResizePolicies([]*resizev1alpha1.ResizePolicy{policy}, pvc, test.lastResizeTime, now)
+			if (violation != nil) != test.wantViolation {
+				t.Fatalf("violation = %+v, want violation %v", violation, test.wantViolation)
+			}
+			if violation == nil {
+				return
+			}
+			if violation.Policy != policy.Name {
+				t.Errorf("violated policy = %s, want %s", violation.Policy, policy.Name)
+			}
+			if violation.RetryAfter != test.wantRetryAfter {
+				t.Errorf("retry after %v, want %v", violation.RetryAfter, test.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestValidatorChecksPoliciesOfPVCNamespace(t *testing.T) {
+	pv, pvc, sc := newResizeTestObjects()
+	registry := NewRegistry()
+	if err := registry.Register(Backend{Name: "test", Resizer: NewContextResizer(&failingResizer{})}); err != nil {
+		t.Fatal(err)
+	}
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pvIndexer.Add(pv)
+	scIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	scIndexer.Add(sc)
+	policyIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	validator := NewValidator(registry, corelisters.NewPersistentVolumeLister(pvIndexer),
+		storagelisters.NewStorageClassLister(scIndexer), resizelisters.NewResizePolicyLister(policyIndexer))
+	oldPVC := pvc.DeepCopy()
+	oldPVC.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("1Gi")
+
+	// A policy of another namespace doesn't apply.
+	otherPolicy := newResizePolicy("other", resizev1alpha1.ResizePolicySpec{MaxSize: quantityPtr("1Gi")})
+	otherPolicy.Namespace = "other"
+	policyIndexer.Add(otherPolicy)
+	if rejection, err := validator.Validate(oldPVC, pvc); err != nil || rejection != nil {
+		t.Fatalf("Validate() = %+v, %v, want no rejection", rejection, err)
+	}
+
+	policyIndexer.Add(newResizePolicy("max-size", resizev1alpha1.ResizePolicySpec{MaxSize: quantityPtr("1Gi")}))
+	rejection, err := validator.Validate(oldPVC, pvc)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if rejection == nil || rejection.Reason != util.ResizePolicyViolated {
+		t.Errorf("rejection = %+v, want reason %s", rejection, util.ResizePolicyViolated)
+	}
+}
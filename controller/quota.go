@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// storageClassRequestsStorage returns the quota resource name limiting total requested storage of the StorageClass.
+func storageClassRequestsStorage(scName string) v1.ResourceName {
+	return v1.ResourceName(scName + ".storageclass.storage.k8s.io/" + string(v1.ResourceRequestsStorage))
+}
+
+// expansionExceedsQuota checks if expanding pvc to its request size exceeds any ResourceQuota
+// in its namespace, returns a message explaining which quota is exceeded if so.
+// Usages are calculated from requests of all PVCs in the namespace rather than quota status,
+// as quota status may not reflect the new request size yet.
+func (ctrl *resizeController) expansionExceedsQuota(pvc *v1.PersistentVolumeClaim) (bool, string, error) {
+	quotas, err := ctrl.quotaLister.ResourceQuotas(pvc.Namespace).List(labels.Everything())
+	if err != nil {
+		return false, "", fmt.Errorf("list ResourceQuotas in namespace %s failed: %v", pvc.Namespace, err)
+	}
+	if len(quotas) == 0 {
+		return false, "", nil
+	}
+
+	pvcs, err := ctrl.pvcLister.PersistentVolumeClaims(pvc.Namespace).List(labels.Everything())
+	if err != nil {
+		return false, "", fmt.Errorf("list PVCs in namespace %s failed: %v", pvc.Namespace, err)
+	}
+	scName := util.GetPVCStorageClass(pvc)
+	scResource := storageClassRequestsStorage(scName)
+	used := map[v1.ResourceName]*resource.Quantity{
+		v1.ResourceRequestsStorage: resource.NewQuantity(0, resource.BinarySI),
+		scResource:                 resource.NewQuantity(0, resource.BinarySI),
+	}
+	for _, claim := range pvcs {
+		if claim.Name == pvc.Name {
+			// Use the PVC being processed in case the lister is stale.
+			claim = pvc
+		}
+		request := claim.Spec.Resources.Requests[v1.ResourceStorage]
+		used[v1.ResourceRequestsStorage].Add(request)
+		if scName != "" && util.GetPVCStorageClass(claim) == scName {
+			used[scResource].Add(request)
+		}
+	}
+
+	for _, quota := range quotas {
+		for name, usage := range used {
+			if name == scResource && scName == "" {
+				continue
+			}
+			hard, ok := quota.Spec.Hard[name]
+			if !ok || usage.Cmp(hard) <= 0 {
+				continue
+			}
+			requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+			return true, fmt.Sprintf("Expanding to %s exceeds quota %s: %s would be %s, limited to %s, "+
+				"the expansion will be retried once the quota is freed or raised",
+				requestSize.String(), quota.Name, name, usage.String(), hard.String()), nil
+		}
+	}
+	return false, "", nil
+}
+
+// addResourceQuota requeues PVCs in the namespace of the quota, as they may be blocked by the quota before.
+func (ctrl *resizeController) addResourceQuota(obj interface{}) {
+	quota, ok := obj.(*v1.ResourceQuota)
+	if !ok {
+		return
+	}
+	pvcs, err := ctrl.pvcLister.PersistentVolumeClaims(quota.Namespace).List(labels.Everything())
+	if err != nil {
+		ctrl.logger.Error(err, "List PVCs failed", "namespace", quota.Namespace)
+		return
+	}
+	for _, pvc := range pvcs {
+		ctrl.enqueuePVC(pvc)
+	}
+}
+
+func (ctrl *resizeController) updateResourceQuota(oldObj, newObj interface{}) {
+	oldQuota, ok := oldObj.(*v1.ResourceQuota)
+	if !ok {
+		return
+	}
+	newQuota, ok := newObj.(*v1.ResourceQuota)
+	if !ok {
+		return
+	}
+	if oldQuota.ResourceVersion == newQuota.ResourceVersion {
+		// Periodic resync, PVCs will be resynced by their own informer.
+		return
+	}
+	ctrl.addResourceQuota(newQuota)
+}
+
+func (ctrl *resizeController) deleteResourceQuota(obj interface{}) {
+	if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok && unknown.Obj != nil {
+		obj = unknown.Obj
+	}
+	ctrl.addResourceQuota(obj)
+}
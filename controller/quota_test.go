@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestPVC(namespace, name, scName, requestSize string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &scName,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(requestSize)},
+			},
+		},
+	}
+}
+
+func TestExpansionExceedsQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		hard        v1.ResourceList
+		wantExceeds bool
+	}{
+		{
+			name: "no storage limit",
+			hard: v1.ResourceList{v1.ResourcePods: resource.MustParse("10")},
+		},
+		{
+			name: "namespace limit satisfied",
+			hard: v1.ResourceList{v1.ResourceRequestsStorage: resource.MustParse("5Gi")},
+		},
+		{
+			name:        "namespace limit exceeded",
+			hard:        v1.ResourceList{v1.ResourceRequestsStorage: resource.MustParse("4Gi")},
+			wantExceeds: true,
+		},
+		{
+			name: "StorageClass limit satisfied",
+			hard: v1.ResourceList{storageClassRequestsStorage("sc"): resource.MustParse("2Gi")},
+		},
+		{
+			name:        "StorageClass limit exceeded",
+			hard:        v1.ResourceList{storageClassRequestsStorage("sc"): resource.MustParse("1Gi")},
+			wantExceeds: true,
+		},
+		{
+			name: "limit of another StorageClass",
+			hard: v1.ResourceList{storageClassRequestsStorage("other-sc"): resource.MustParse("1Gi")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pvc := newTestPVC("default", "pvc", "sc", "2Gi")
+			pvcIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			// The cache is stale, the PVC being expanded is counted by its new request size.
+			pvcIndexer.Add(newTestPVC("default", "pvc", "sc", "1Gi"))
+			pvcIndexer.Add(newTestPVC("default", "other", "other-sc", "3Gi"))
+			// PVCs of other namespaces are not counted.
+			pvcIndexer.Add(newTestPVC("other", "pvc", "sc", "100Gi"))
+			quotaIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			quotaIndexer.Add(&v1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "default"},
+				Spec:       v1.ResourceQuotaSpec{Hard: test.hard},
+			})
+			ctrl := &resizeController{
+				pvcLister:   corelisters.NewPersistentVolumeClaimLister(pvcIndexer),
+				quotaLister: corelisters.NewResourceQuotaLister(quotaIndexer),
+			}
+
+			exceeds, message, err := ctrl.expansionExceedsQuota(pvc)
+			if err != nil {
+				t.Fatalf("expansionExceedsQuota failed: %v", err)
+			}
+			if exceeds != test.wantExceeds {
+				t.Errorf("exceeds = %v (%q), want %v", exceeds, message, test.wantExceeds)
+			}
+		})
+	}
+}
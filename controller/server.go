@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricConfig configures the HTTP server serving metrics, health checks and profiling of the controller.
+type MetricConfig struct {
+	// Path of metrics, health checks are served on /healthz and /readyz.
+	Path    string
+	Address string
+
+	// CertFile and KeyFile enable TLS if both are set, setting only one of them is an error.
+	CertFile string
+	KeyFile  string
+
+	// EnablePprof serves profiling data on /debug/pprof.
+	EnablePprof bool
+}
+
+// serverShutdownTimeout is how long the server waits for in-flight requests when shutting down.
+const serverShutdownTimeout = 5 * time.Second
+
+// informerSyncTimeout is how long informer caches may take to sync before the controller is considered unhealthy.
+const informerSyncTimeout = 2 * time.Minute
+
+// startMetricsServer starts serving metrics and health checks until ctx is done. It runs regardless of
+// leadership, so that standby replicas are observable too. An error is returned if the server can't listen
+// on the address or load its certificate, otherwise the returned channel receives the error the server
+// stopped with, nil if it was shut down after ctx is done.
+func (ctrl *resizeController) startMetricsServer(ctx context.Context, config *MetricConfig) (<-chan error, error) {
+	mux := http.NewServeMux()
+	mux.Handle(config.Path, promhttp.HandlerFor(ctrl.metrics.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", ctrl.serveHealthz)
+	mux.HandleFunc("/readyz", ctrl.serveReadyz)
+	if config.EnablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	server := &http.Server{Addr: config.Address, Handler: mux}
+
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, fmt.Errorf("both cert file and key file of metrics server must be set to enable TLS, got %q and %q",
+			config.CertFile, config.KeyFile)
+	}
+	tlsEnabled := config.CertFile != ""
+	if tlsEnabled {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate of metrics server: %v", err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("metrics server failed to listen on %s: %v", config.Address, err)
+	}
+
+	serveErrCh := make(chan error, 1)
+	go func() {
+		ctrl.logger.Info("Starting metrics server", "address", listener.Addr().String())
+		if tlsEnabled {
+			serveErrCh <- server.ServeTLS(listener, "", "")
+		} else {
+			serveErrCh <- server.Serve(listener)
+		}
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		select {
+		case err := <-serveErrCh:
+			errCh <- fmt.Errorf("metrics server failed: %v", err)
+			return
+		case <-ctx.Done():
+		}
+
+		ctrl.logger.Info("Shutting down metrics server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			ctrl.logger.Error(err, "Shut down metrics server failed")
+		}
+		errCh <- nil
+	}()
+	return errCh, nil
+}
+
+// healthCheck is the result of a named check, err is nil if the check passed.
+type healthCheck struct {
+	name string
+	err  error
+}
+
+// serveHealthz reports whether the controller is alive, i.e. no worker is stuck and informers synced in time.
+func (ctrl *resizeController) serveHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeHealthChecks(w, []healthCheck{
+		{name: "workers", err: ctrl.workers.check()},
+		{name: "informers", err: ctrl.status.checkInformers()},
+	})
+}
+
+// serveReadyz reports whether the controller is processing PVCs, i.e. it is the leader and caches are synced.
+func (ctrl *resizeController) serveReadyz(w http.ResponseWriter, _ *http.Request) {
+	writeHealthChecks(w, []healthCheck{
+		{name: "leader", err: ctrl.status.checkLeading()},
+		{name: "informers", err: ctrl.status.checkSynced()},
+	})
+}
+
+func writeHealthChecks(w http.ResponseWriter, checks []healthCheck) {
+	var output strings.Builder
+	failed := false
+	for _, check := range checks {
+		if check.err != nil {
+			failed = true
+			fmt.Fprintf(&output, "[-]%s failed: %v\n", check.name, check.err)
+		} else {
+			fmt.Fprintf(&output, "[+]%s ok\n", check.name)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if failed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprint(w, output.String())
+}
+
+// runStatus tracks whether the controller is running, i.e. it is the leader, and whether its caches are synced.
+type runStatus struct {
+	lock      sync.RWMutex
+	running   bool
+	startTime time.Time
+	synced    bool
+}
+
+func (s *runStatus) start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.running, s.startTime, s.synced = true, time.Now(), false
+}
+
+func (s *runStatus) setSynced() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.synced = true
+}
+
+func (s *runStatus) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.running, s.synced = false, false
+}
+
+func (s *runStatus) checkLeading() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if !s.running {
+		return fmt.Errorf("not leader")
+	}
+	return nil
+}
+
+func (s *runStatus) checkSynced() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if !s.synced {
+		return fmt.Errorf("caches not synced")
+	}
+	return nil
+}
+
+// checkInformers fails if caches are not synced within informerSyncTimeout after the controller starts running.
+func (s *runStatus) checkInformers() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.running && !s.synced && time.Since(s.startTime) > informerSyncTimeout {
+		return fmt.Errorf("caches not synced in %v", informerSyncTimeout)
+	}
+	return nil
+}
+
+// workerTracker tracks PVCs being processed by workers, to detect workers stuck on a PVC.
+type workerTracker struct {
+	// threshold is how long a worker may process a PVC before it is considered stuck, 0 disables the check.
+	threshold time.Duration
+
+	lock       sync.Mutex
+	processing map[string]time.Time
+}
+
+func newWorkerTracker(threshold time.Duration) *workerTracker {
+	return &workerTracker{
+		threshold:  threshold,
+		processing: make(map[string]time.Time),
+	}
+}
+
+func (t *workerTracker) start(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.processing[key] = time.Now()
+}
+
+func (t *workerTracker) done(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.processing, key)
+}
+
+func (t *workerTracker) check() error {
+	if t.threshold <= 0 {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var stuck []string
+	for key, startTime := range t.processing {
+		if time.Since(startTime) > t.threshold {
+			stuck = append(stuck, key)
+		}
+	}
+	if len(stuck) > 0 {
+		sort.Strings(stuck)
+		return fmt.Errorf("workers stuck on %s for more than %v", strings.Join(stuck, ", "), t.threshold)
+	}
+	return nil
+}
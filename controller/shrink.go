@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/api/core/v1"
+)
+
+// shrinkRequests remembers PVCs whose request size is lowered below their actual size by an update.
+// A request size below the actual size alone doesn't mean a shrink, e.g. a PVC statically bound to
+// a bigger PV or rounded up by the provisioner requests less than it has, so only such updates are
+// taken as shrink requests.
+type shrinkRequests struct {
+	lock sync.Mutex
+	keys map[string]bool
+}
+
+func newShrinkRequests() *shrinkRequests {
+	return &shrinkRequests{keys: make(map[string]bool)}
+}
+
+// update records the shrink request if the request size of the PVC is lowered below its actual size
+// from oldPVC to newPVC. A recorded request is kept while the request size stays below the actual size,
+// e.g. the request size is changed again before the shrink, and is forgotten otherwise.
+func (r *shrinkRequests) update(oldPVC, newPVC *v1.PersistentVolumeClaim) {
+	key := util.PVCKey(newPVC)
+	requestSize := newPVC.Spec.Resources.Requests[v1.ResourceStorage]
+	oldRequestSize := oldPVC.Spec.Resources.Requests[v1.ResourceStorage]
+	actualSize, ok := newPVC.Status.Capacity[v1.ResourceStorage]
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !ok || requestSize.Cmp(actualSize) >= 0 {
+		delete(r.keys, key)
+		return
+	}
+	if requestSize.Cmp(oldRequestSize) < 0 {
+		r.keys[key] = true
+	}
+}
+
+func (r *shrinkRequests) has(key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.keys[key]
+}
+
+func (r *shrinkRequests) forget(key string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.keys, key)
+}
+
+// pvcShrinking returns true if pvc requests to shrink its volume: its request size is below its actual size,
+// and it is lowered by an update we observed, or a shrink to it was started before and is recorded on pv,
+// e.g. by the resizer before it restarted.
+func (ctrl *resizeController) pvcShrinking(pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) bool {
+	if pvc.Status.Phase != v1.ClaimBound {
+		return false
+	}
+	actualSize, ok := pvc.Status.Capacity[v1.ResourceStorage]
+	requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !ok || requestSize.Cmp(actualSize) >= 0 {
+		return false
+	}
+	if ctrl.shrinkRequests.has(util.PVCKey(pvc)) {
+		return true
+	}
+	op, err := util.GetResizeOperation(pv)
+	return err == nil && op != nil && op.TargetSize.Cmp(requestSize) == 0
+}
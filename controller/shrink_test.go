@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// shrinkableResizer supports shrinking volumes, it resizes volumes to the request size and records the sizes.
+type shrinkableResizer struct {
+	lock  sync.Mutex
+	sizes []string
+}
+
+func (r *shrinkableResizer) CanSupport(*v1.PersistentVolume) bool {
+	return true
+}
+
+func (r *shrinkableResizer) Resize(_ *v1.PersistentVolume, requestSize resource.Quantity) (resource.Quantity, bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sizes = append(r.sizes, requestSize.String())
+	return requestSize, false, nil
+}
+
+func (r *shrinkableResizer) Capabilities() Capabilities {
+	return Capabilities{OnlineExpansion: true, OfflineExpansion: true, Shrink: true}
+}
+
+func (r *shrinkableResizer) resizedSizes() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.sizes...)
+}
+
+func TestSyncPVCShrink(t *testing.T) {
+	tests := []struct {
+		name string
+		// oldRequestSize is the request size before an update observed by the controller, empty if no update.
+		oldRequestSize string
+		requestSize    string
+		// operationSize is the target size of the resize operation recorded on the PV, empty if none.
+		operationSize string
+		wantSizes     []string
+	}{
+		{
+			name:        "PVC bound to a bigger volume",
+			requestSize: "1Gi",
+		},
+		{
+			name:           "request size raised below the actual size",
+			oldRequestSize: "1Gi",
+			requestSize:    "5Gi",
+		},
+		{
+			name:           "request size lowered below the actual size",
+			oldRequestSize: "10Gi",
+			requestSize:    "5Gi",
+			wantSizes:      []string{"5Gi"},
+		},
+		{
+			name:           "request size lowered but above the actual size",
+			oldRequestSize: "20Gi",
+			requestSize:    "15Gi",
+			wantSizes:      []string{"15Gi"},
+		},
+		{
+			name:          "shrink recorded by a previous attempt",
+			requestSize:   "5Gi",
+			operationSize: "5Gi",
+			wantSizes:     []string{"5Gi"},
+		},
+		{
+			name:          "expansion recorded by a previous attempt",
+			requestSize:   "1Gi",
+			operationSize: "20Gi",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pv, pvc, sc := newResizeTestObjects()
+			pv.Spec.Capacity[v1.ResourceStorage] = resource.MustParse("10Gi")
+			pvc.Status.Capacity[v1.ResourceStorage] = resource.MustParse("10Gi")
+			pvc.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse(test.requestSize)
+			pvc.Annotations = map[string]string{util.AllowVolumeShrinkAnnotation: "true"}
+			if test.operationSize != "" {
+				value, err := json.Marshal(&util.ResizeOperation{
+					ID:         "op",
+					TargetSize: resource.MustParse(test.operationSize),
+					Backend:    "test",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				pv.Annotations = map[string]string{util.ResizeOperationAnnotation: string(value)}
+			}
+
+			kubeClient := fake.NewSimpleClientset(pv, pvc, sc)
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+			resizer := &shrinkableResizer{}
+			registry := NewRegistry()
+			if err := registry.Register(Backend{Name: "test", Resizer: NewContextResizer(resizer)}); err != nil {
+				t.Fatal(err)
+			}
+			ctrl := NewResizeController("test", registry, kubeClient, time.Hour, time.Minute,
+				WithInformerFactory(informerFactory)).(*resizeController)
+			ctrl.resizeFunc = ctrl.resizePVC
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			informerFactory.Start(stopCh)
+			informerFactory.WaitForCacheSync(stopCh)
+
+			if test.oldRequestSize != "" {
+				oldPVC := pvc.DeepCopy()
+				oldPVC.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse(test.oldRequestSize)
+				ctrl.updatePVC(oldPVC, pvc)
+			}
+			kubeClient.ClearActions()
+			if err := ctrl.syncPVC(context.Background(), ctrl.backends[0], util.PVCKey(pvc)); err != nil {
+				t.Fatalf("syncPVC failed: %v", err)
+			}
+
+			if sizes := resizer.resizedSizes(); len(sizes) != len(test.wantSizes) ||
+				(len(sizes) > 0 && sizes[0] != test.wantSizes[0]) {
+				t.Errorf("volume is resized to %v, want %v", sizes, test.wantSizes)
+			}
+			if len(test.wantSizes) == 0 {
+				// Neither a condition nor anything else is written for PVCs which aren't resized.
+				for _, action := range kubeClient.Actions() {
+					if action.GetVerb() != "get" && action.GetVerb() != "list" && action.GetVerb() != "watch" {
+						t.Errorf("unexpected action %s on %s", action.GetVerb(), action.GetResource().Resource)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of spans created by the controller.
+const tracerName = "github.com/mlmhl/external-resizer/controller"
+
+// startSpan starts a span of a resize stage as a child of the span in ctx.
+func (ctrl *resizeController) startSpan(
+	ctx context.Context,
+	name string,
+	options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return ctrl.tracer.Start(ctx, name, options...)
+}
+
+// endSpan marks span as failed if err isn't nil, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// spanLinks remembers the span of the last resize attempt of each PVC,
+// so that the span of a retry is linked to the span of the previous attempt.
+type spanLinks struct {
+	lock  sync.Mutex
+	spans map[string]trace.SpanContext
+}
+
+func newSpanLinks() *spanLinks {
+	return &spanLinks{spans: make(map[string]trace.SpanContext)}
+}
+
+// links returns links to the span of the previous attempt of key, if any.
+func (l *spanLinks) links(key string) []trace.Link {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if previous, ok := l.spans[key]; ok {
+		return []trace.Link{{SpanContext: previous}}
+	}
+	return nil
+}
+
+func (l *spanLinks) set(key string, span trace.SpanContext) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.spans[key] = span
+}
+
+func (l *spanLinks) forget(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.spans, key)
+}
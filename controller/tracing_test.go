@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// flakyResizer fails the first failures resizes, and resizes volumes to the request size afterwards.
+type flakyResizer struct {
+	failures int
+}
+
+func (r *flakyResizer) CanSupport(*v1.PersistentVolume) bool {
+	return true
+}
+
+func (r *flakyResizer) Resize(_ *v1.PersistentVolume, requestSize resource.Quantity) (resource.Quantity, bool, error) {
+	if r.failures > 0 {
+		r.failures--
+		return resource.Quantity{}, false, fmt.Errorf("backend is unavailable")
+	}
+	return requestSize, false, nil
+}
+
+func TestResizePVCSpans(t *testing.T) {
+	pv, pvc, sc := newResizeTestObjects()
+	kubeClient := fake.NewSimpleClientset(pv, pvc, sc)
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	registry := NewRegistry()
+	if err := registry.Register(Backend{Name: "test", Resizer: NewContextResizer(&flakyResizer{failures: 1})}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctrl := NewResizeController("test", registry, kubeClient, time.Hour, time.Minute,
+		WithInformerFactory(informerFactory), WithTracerProvider(provider)).(*resizeController)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+
+	b := ctrl.backends[0]
+	if err := ctrl.resizePVC(context.Background(), b, pvc, pv); err == nil {
+		t.Fatalf("first resize succeeded, want error")
+	}
+	if err := ctrl.resizePVC(context.Background(), b, pvc, pv); err != nil {
+		t.Fatalf("second resize failed: %v", err)
+	}
+
+	var roots []sdktrace.ReadOnlySpan
+	children := make(map[string][]string)
+	for _, span := range recorder.Ended() {
+		if !span.Parent().IsValid() {
+			roots = append(roots, span)
+			continue
+		}
+		parent := span.Parent().SpanID().String()
+		children[parent] = append(children[parent], span.Name())
+	}
+	if len(roots) != 2 {
+		t.Fatalf("got %d root spans, want 2", len(roots))
+	}
+
+	wantChildren := [][]string{
+		{"MarkPVCResizeInProgress", "StartResizeOperation", "Resizer.Resize"},
+		{"MarkPVCResizeInProgress", "StartResizeOperation", "Resizer.Resize", "UpdatePVCapacity", "MarkPVCResizeFinished"},
+	}
+	for i, root := range roots {
+		if root.Name() != "ResizePVC" {
+			t.Errorf("root span %d is %s, want ResizePVC", i, root.Name())
+		}
+		if got := children[root.SpanContext().SpanID().String()]; fmt.Sprint(got) != fmt.Sprint(wantChildren[i]) {
+			t.Errorf("children of attempt %d are %v, want %v", i+1, got, wantChildren[i])
+		}
+	}
+
+	// The retry is linked to the failed attempt.
+	if links := roots[0].Links(); len(links) != 0 {
+		t.Errorf("first attempt has %d links, want 0", len(links))
+	}
+	links := roots[1].Links()
+	if len(links) != 1 {
+		t.Fatalf("second attempt has %d links, want 1", len(links))
+	}
+	if links[0].SpanContext.SpanID() != roots[0].SpanContext().SpanID() {
+		t.Errorf("second attempt links to span %s, want %s",
+			links[0].SpanContext.SpanID(), roots[0].SpanContext().SpanID())
+	}
+
+	// Following resizes of the PVC start over without links once it succeeds.
+	if spanLinks := ctrl.spanLinks.links("default/pvc"); len(spanLinks) != 0 {
+		t.Errorf("got %d links after the resize succeeded, want 0", len(spanLinks))
+	}
+}
@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	resizelisters "github.com/mlmhl/external-resizer/client/listers/resize/v1alpha1"
+	"github.com/mlmhl/external-resizer/logging"
+	"github.com/mlmhl/external-resizer/util"
+
+	"github.com/go-logr/logr"
+	"k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+)
+
+// Rejection explains why a resize request is refused.
+type Rejection struct {
+	// Reason is a CamelCase reason used by PVC conditions and events, e.g. util.VolumeExpansionNotAllowed.
+	Reason  string
+	Message string
+	// RetryAfter is set if the request will be allowed after this duration without any change.
+	RetryAfter time.Duration
+}
+
+// Validator checks resize requests against StorageClasses, capabilities of backends and ResizePolicies.
+// The resize controller runs the same checks before resizing volumes, so requests rejected by
+// the Validator would be refused by the controller anyway.
+type Validator struct {
+	logger       logr.Logger
+	backends     []*backend
+	pvLister     corelisters.PersistentVolumeLister
+	scLister     storagelisters.StorageClassLister
+	policyLister resizelisters.ResizePolicyLister
+}
+
+// NewValidator creates a Validator for backends of the registry.
+// policyLister is optional, ResizePolicies are not enforced if it is nil.
+func NewValidator(
+	registry *Registry,
+	pvLister corelisters.PersistentVolumeLister,
+	scLister storagelisters.StorageClassLister,
+	policyLister resizelisters.ResizePolicyLister) *Validator {
+	var backends []*backend
+	for _, registered := range registry.Backends() {
+		backends = append(backends, &backend{
+			Backend:      registered,
+			capabilities: GetCapabilities(registered.Resizer),
+		})
+	}
+	return newValidator(logging.Logger(), backends, pvLister, scLister, policyLister)
+}
+
+func newValidator(
+	logger logr.Logger,
+	backends []*backend,
+	pvLister corelisters.PersistentVolumeLister,
+	scLister storagelisters.StorageClassLister,
+	policyLister resizelisters.ResizePolicyLister) *Validator {
+	return &Validator{
+		logger:       logger,
+		backends:     backends,
+		pvLister:     pvLister,
+		scLister:     scLister,
+		policyLister: policyLister,
+	}
+}
+
+// Validate checks the request size of pvc updated from oldPVC, returns nil if the request is allowed,
+// or pvc isn't resized, or its volume isn't served by any backend.
+// The update shrinks the volume only if the request size is lowered below the actual size, a request size
+// below the actual size alone is ignored by the controller, e.g. a PVC bound to a bigger PV.
+func (v *Validator) Validate(oldPVC, pvc *v1.PersistentVolumeClaim) (*Rejection, error) {
+	if pvc.Spec.VolumeName == "" {
+		return nil, nil
+	}
+	actualSize, ok := pvc.Status.Capacity[v1.ResourceStorage]
+	if !ok {
+		return nil, nil
+	}
+	requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	oldRequestSize := oldPVC.Spec.Resources.Requests[v1.ResourceStorage]
+	shrinking := requestSize.Cmp(actualSize) < 0 && requestSize.Cmp(oldRequestSize) < 0
+	if !shrinking && requestSize.Cmp(actualSize) <= 0 {
+		return nil, nil
+	}
+
+	pv, err := v.pvLister.Get(pvc.Spec.VolumeName)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get PV %s failed: %v", pvc.Spec.VolumeName, err)
+	}
+	b := v.route(pv)
+	if b == nil {
+		return nil, nil
+	}
+	return v.check(b, pvc, pv, shrinking)
+}
+
+// route returns the backend responsible for the PV, or nil if no backend supports it.
+// A backend matched by driver name or provisioner is only returned if its resizer supports the PV as well.
+func (v *Validator) route(pv *v1.PersistentVolume) *backend {
+	if source := pv.Spec.CSI; source != nil {
+		for _, b := range v.backends {
+			if b.DriverName != "" && b.DriverName == source.Driver && v.canSupport(b, pv, "driver") {
+				return b
+			}
+		}
+	}
+
+	if scName := pv.Spec.StorageClassName; scName != "" {
+		sc, err := v.scLister.Get(scName)
+		if err != nil {
+			v.logger.V(4).Info("Get StorageClass of PV failed", logging.KeyStorageClass, scName, logging.KeyPV, pv.Name, "err", err)
+		} else {
+			for _, b := range v.backends {
+				for _, provisioner := range b.Provisioners {
+					if provisioner == sc.Provisioner && v.canSupport(b, pv, "provisioner") {
+						return b
+					}
+				}
+			}
+		}
+	}
+
+	for _, b := range v.backends {
+		if b.Resizer.CanSupport(pv) {
+			return b
+		}
+	}
+	return nil
+}
+
+// canSupport checks if the resizer of backend b, which is matched to the PV by matchedBy, supports the PV.
+func (v *Validator) canSupport(b *backend, pv *v1.PersistentVolume, matchedBy string) bool {
+	if b.Resizer.CanSupport(pv) {
+		return true
+	}
+	v.logger.Info("Resizer of matched backend doesn't support PV, ignoring the match",
+		logging.KeyPV, pv.Name, "matchedBy", matchedBy)
+	return false
+}
+
+// check runs checks which only depend on the request itself, i.e. the StorageClass, ResizePolicies
+// and capabilities of the backend. Checks depending on pods using the volume are left to the controller,
+// as they are transient and the controller waits for them. shrinking tells whether pvc requests to shrink
+// its volume, otherwise it requests to expand the volume.
+func (v *Validator) check(
+	b *backend,
+	pvc *v1.PersistentVolumeClaim,
+	pv *v1.PersistentVolume,
+	shrinking bool) (*Rejection, error) {
+	if allowed, message, err := v.volumeExpansionAllowed(pvc); err != nil {
+		return nil, fmt.Errorf("check if volume expansion is allowed failed: %v", err)
+	} else if !allowed {
+		return &Rejection{Reason: util.VolumeExpansionNotAllowed, Message: message}, nil
+	}
+
+	if v.policyLister != nil {
+		policies, err := v.policyLister.ResizePolicies(pvc.Namespace).List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("list ResizePolicies in namespace %s failed: %v", pvc.Namespace, err)
+		}
+		if violation := CheckResizePolicies(policies, pvc, util.GetLastResizeTime(pv), time.Now()); violation != nil {
+			return &Rejection{
+				Reason:     util.ResizePolicyViolated,
+				Message:    violation.Message,
+				RetryAfter: violation.RetryAfter,
+			}, nil
+		}
+	}
+
+	if shrinking {
+		if allowed, message, err := v.volumeShrinkAllowed(b, pvc); err != nil {
+			return nil, fmt.Errorf("check if volume shrink is allowed failed: %v", err)
+		} else if !allowed {
+			return &Rejection{Reason: util.VolumeShrinkRejected, Message: message}, nil
+		}
+	} else if !b.capabilities.OnlineExpansion && !b.capabilities.OfflineExpansion {
+		return &Rejection{
+			Reason:  util.VolumeExpansionNotSupported,
+			Message: fmt.Sprintf("Backend %s doesn't support expanding volumes", b.Name),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// volumeExpansionAllowed checks if the StorageClass of pvc sets allowVolumeExpansion,
+// returns a message explaining the reason if not.
+func (v *Validator) volumeExpansionAllowed(pvc *v1.PersistentVolumeClaim) (bool, string, error) {
+	scName := util.GetPVCStorageClass(pvc)
+	if scName == "" {
+		return false, "Volume expansion is only allowed for PVCs with a StorageClass which sets allowVolumeExpansion to true", nil
+	}
+	sc, err := v.scLister.Get(scName)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, fmt.Sprintf("StorageClass %s not found, can't determine if volume expansion is allowed", scName), nil
+		}
+		return false, "", err
+	}
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		return false, fmt.Sprintf("StorageClass %s doesn't allow volume expansion, allowVolumeExpansion must be set to true", scName), nil
+	}
+	return true, "", nil
+}
+
+// volumeShrinkAllowed checks if the resizer supports shrink and the PVC or its StorageClass opts in,
+// returns a message explaining the reason if not.
+func (v *Validator) volumeShrinkAllowed(b *backend, pvc *v1.PersistentVolumeClaim) (bool, string, error) {
+	if !b.capabilities.Shrink {
+		return false, fmt.Sprintf("Backend %s doesn't support shrinking volumes", b.Name), nil
+	}
+
+	optedIn := util.ShrinkAllowed(pvc.Annotations)
+	if !optedIn {
+		sc, err := v.scLister.Get(util.GetPVCStorageClass(pvc))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return false, "", err
+		}
+		optedIn = sc != nil && util.ShrinkAllowed(sc.Annotations)
+	}
+	if !optedIn {
+		return false, fmt.Sprintf("Volume shrink must be enabled by annotating the PVC or its StorageClass with %s=true",
+			util.AllowVolumeShrinkAnnotation), nil
+	}
+
+	return true, "", nil
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/mlmhl/external-resizer/util"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestValidateShrink(t *testing.T) {
+	tests := []struct {
+		name           string
+		oldRequestSize string
+		requestSize    string
+		shrinkOptedIn  bool
+		shrinkBackend  bool
+		wantReason     string
+	}{
+		{
+			name:           "request size raised below the actual size",
+			oldRequestSize: "1Gi",
+			requestSize:    "5Gi",
+		},
+		{
+			name:           "request size lowered below the actual size",
+			oldRequestSize: "10Gi",
+			requestSize:    "5Gi",
+			shrinkOptedIn:  true,
+			shrinkBackend:  true,
+		},
+		{
+			name:           "shrink not opted in",
+			oldRequestSize: "10Gi",
+			requestSize:    "5Gi",
+			shrinkBackend:  true,
+			wantReason:     util.VolumeShrinkRejected,
+		},
+		{
+			name:           "shrink not supported by the backend",
+			oldRequestSize: "10Gi",
+			requestSize:    "5Gi",
+			shrinkOptedIn:  true,
+			wantReason:     util.VolumeShrinkRejected,
+		},
+		{
+			name:           "request size lowered but above the actual size",
+			oldRequestSize: "20Gi",
+			requestSize:    "15Gi",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pv, pvc, sc := newResizeTestObjects()
+			pv.Spec.Capacity[v1.ResourceStorage] = resource.MustParse("10Gi")
+			pvc.Status.Capacity[v1.ResourceStorage] = resource.MustParse("10Gi")
+			pvc.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse(test.requestSize)
+			if test.shrinkOptedIn {
+				pvc.Annotations = map[string]string{util.AllowVolumeShrinkAnnotation: "true"}
+			}
+			oldPVC := pvc.DeepCopy()
+			oldPVC.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse(test.oldRequestSize)
+
+			var resizer Resizer = &failingResizer{}
+			if test.shrinkBackend {
+				resizer = &shrinkableResizer{}
+			}
+			registry := NewRegistry()
+			if err := registry.Register(Backend{Name: "test", Resizer: NewContextResizer(resizer)}); err != nil {
+				t.Fatal(err)
+			}
+			pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			pvIndexer.Add(pv)
+			scIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			scIndexer.Add(sc)
+			validator := NewValidator(registry, corelisters.NewPersistentVolumeLister(pvIndexer),
+				storagelisters.NewStorageClassLister(scIndexer), nil)
+
+			rejection, err := validator.Validate(oldPVC, pvc)
+			if err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+			if test.wantReason == "" && rejection != nil {
+				t.Errorf("request is rejected: %+v", rejection)
+			}
+			if test.wantReason != "" && (rejection == nil || rejection.Reason != test.wantReason) {
+				t.Errorf("rejection = %+v, want reason %s", rejection, test.wantReason)
+			}
+		})
+	}
+}
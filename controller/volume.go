@@ -1,6 +1,12 @@
 package controller
 
 import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mlmhl/external-resizer/util"
+
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
@@ -9,3 +15,149 @@ type Resizer interface {
 	CanSupport(pv *v1.PersistentVolume) bool
 	Resize(pv *v1.PersistentVolume, requestSize resource.Quantity) (newSize resource.Quantity, fsResizeRequired bool, err error)
 }
+
+// ResizeRequest describes a resize operation.
+type ResizeRequest struct {
+	PV  *v1.PersistentVolume
+	PVC *v1.PersistentVolumeClaim
+
+	RequestSize resource.Quantity
+
+	// Parameters are parameters of the PVC's StorageClass, parameters reserved by the resizer are excluded.
+	Parameters map[string]string
+	// Secrets is the data of the Secret referenced by the StorageClass, nil if no Secret is referenced.
+	Secrets map[string]string
+
+	// Operation is the resize operation recorded on the PV before calling the resizer.
+	// Resumed is true if the operation was started before but not finished, e.g. the resizer died
+	// before updating the PV. Resizers can use Operation.ID to resume the operation idempotently.
+	Operation *util.ResizeOperation
+	Resumed   bool
+}
+
+// ContextResizer is a Resizer whose Resize receives a context. The context carries
+// the deadline of the resize operation and is cancelled when the controller stops
+// or loses leadership, implementations should abort as soon as it is done.
+// It also carries a logger with fields of the PVC, see logging.FromContext.
+type ContextResizer interface {
+	CanSupport(pv *v1.PersistentVolume) bool
+	Resize(ctx context.Context, req *ResizeRequest) (newSize resource.Quantity, fsResizeRequired bool, err error)
+}
+
+// Capabilities describes what a resizer is able to do.
+type Capabilities struct {
+	// OnlineExpansion means volumes can be expanded while they are used by pods.
+	OnlineExpansion bool
+	// OfflineExpansion means volumes can be expanded while they are not used by any pod.
+	OfflineExpansion bool
+	// Shrink means volumes can be shrunk. Shrinking is only performed when the PVC or its StorageClass
+	// opts in and no pod is using the volume. File system on the volume must be shrunk by the resizer
+	// itself before the volume is shrunk.
+	Shrink bool
+	// FileSystemResize means file system may need to be resized on node after the volume is expanded.
+	FileSystemResize bool
+}
+
+// CapabilitiesProvider can be implemented by resizers to declare their capabilities.
+type CapabilitiesProvider interface {
+	Capabilities() Capabilities
+}
+
+// defaultCapabilities are capabilities of resizers which don't implement CapabilitiesProvider.
+var defaultCapabilities = Capabilities{
+	OnlineExpansion:  true,
+	OfflineExpansion: true,
+	FileSystemResize: true,
+}
+
+// GetCapabilities returns capabilities of the resizer, default capabilities are returned
+// if it doesn't implement CapabilitiesProvider.
+func GetCapabilities(resizer interface{}) Capabilities {
+	if provider, ok := resizer.(CapabilitiesProvider); ok {
+		return provider.Capabilities()
+	}
+	return defaultCapabilities
+}
+
+// NewContextResizer adapts a Resizer to ContextResizer.
+// As the underlying Resizer can't be interrupted, Resize returns once the context is done
+// and leaves the underlying call running in background. At most one call runs for a PV at a time:
+// following Resizes of the PV with the same request size wait for the running call and take its result,
+// even if it finishes after the caller started it gave up, others fail until it returns.
+func NewContextResizer(resizer Resizer) ContextResizer {
+	return &contextResizer{resizer: resizer, calls: make(map[string]*resizeCall)}
+}
+
+type contextResizer struct {
+	resizer Resizer
+
+	lock sync.Mutex
+	// calls are underlying Resize calls by PV name, a call is kept until its result is taken.
+	calls map[string]*resizeCall
+}
+
+// resizeCall is a call of the underlying Resizer, its result is set before done is closed.
+type resizeCall struct {
+	requestSize resource.Quantity
+	done        chan struct{}
+
+	newSize          resource.Quantity
+	fsResizeRequired bool
+	err              error
+}
+
+func (r *contextResizer) CanSupport(pv *v1.PersistentVolume) bool {
+	return r.resizer.CanSupport(pv)
+}
+
+func (r *contextResizer) Capabilities() Capabilities {
+	return GetCapabilities(r.resizer)
+}
+
+func (r *contextResizer) Resize(ctx context.Context, req *ResizeRequest) (resource.Quantity, bool, error) {
+	call, err := r.startCall(req)
+	if err != nil {
+		return req.PV.Spec.Capacity[v1.ResourceStorage], false, err
+	}
+
+	select {
+	case <-call.done:
+		r.lock.Lock()
+		if r.calls[req.PV.Name] == call {
+			delete(r.calls, req.PV.Name)
+		}
+		r.lock.Unlock()
+		return call.newSize, call.fsResizeRequired, call.err
+	case <-ctx.Done():
+		return req.PV.Spec.Capacity[v1.ResourceStorage], false, ctx.Err()
+	}
+}
+
+// startCall returns the call of the PV to the same request size if there is one,
+// otherwise it starts a new call unless a call to another size is still running.
+func (r *contextResizer) startCall(req *ResizeRequest) (*resizeCall, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if call, ok := r.calls[req.PV.Name]; ok {
+		if call.requestSize.Cmp(req.RequestSize) == 0 {
+			return call, nil
+		}
+		select {
+		case <-call.done:
+			// The result is stale as the request size was changed, resize the volume again.
+		default:
+			return nil, fmt.Errorf("previous resize of PV %s to %s is still in progress",
+				req.PV.Name, call.requestSize.String())
+		}
+	}
+
+	call := &resizeCall{requestSize: req.RequestSize, done: make(chan struct{})}
+	r.calls[req.PV.Name] = call
+	pv := req.PV
+	go func() {
+		defer close(call.done)
+		call.newSize, call.fsResizeRequired, call.err = r.resizer.Resize(pv, call.requestSize)
+	}()
+	return call, nil
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// blockingResizer resizes volumes to the request size once release is closed.
+type blockingResizer struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (r *blockingResizer) CanSupport(*v1.PersistentVolume) bool {
+	return true
+}
+
+func (r *blockingResizer) Resize(_ *v1.PersistentVolume, requestSize resource.Quantity) (resource.Quantity, bool, error) {
+	atomic.AddInt32(&r.calls, 1)
+	<-r.release
+	return requestSize, false, nil
+}
+
+func TestContextResizerReusesInFlightCall(t *testing.T) {
+	legacy := &blockingResizer{release: make(chan struct{})}
+	resizer := NewContextResizer(legacy)
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv"},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+		},
+	}
+	req := &ResizeRequest{PV: pv, RequestSize: resource.MustParse("2Gi")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := resizer.Resize(ctx, req); err != context.DeadlineExceeded {
+		t.Fatalf("Resize returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// A resize to another size is refused while the first call is running.
+	if _, _, err := resizer.Resize(context.Background(), &ResizeRequest{PV: pv, RequestSize: resource.MustParse("3Gi")}); err == nil {
+		t.Fatalf("Resize to another size succeeded while the previous call is running")
+	}
+
+	// A retry of the same request takes the result of the first call rather than calling the resizer again.
+	close(legacy.release)
+	newSize, _, err := resizer.Resize(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	if newSize.Cmp(req.RequestSize) != 0 {
+		t.Errorf("new size = %s, want %s", newSize.String(), req.RequestSize.String())
+	}
+	if calls := atomic.LoadInt32(&legacy.calls); calls != 1 {
+		t.Errorf("resizer is called %d times, want 1", calls)
+	}
+
+	// The result is taken, following resizes call the resizer again.
+	if _, _, err := resizer.Resize(context.Background(), req); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	if calls := atomic.LoadInt32(&legacy.calls); calls != 2 {
+		t.Errorf("resizer is called %d times, want 2", calls)
+	}
+}
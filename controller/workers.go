@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+)
+
+// workerPool runs workers of each backend while the controller is running,
+// the number of workers can be changed at any time.
+type workerPool struct {
+	lock sync.Mutex
+	size int
+	// work processes a PVC of the backend.
+	work func(b *backend)
+	// done is closed when the current run stops, workers exit then.
+	done <-chan struct{}
+	// stopChs are stop channels of running workers of each backend, nil if the pool isn't running.
+	stopChs map[*backend][]chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newWorkerPool(size int) *workerPool {
+	return &workerPool{size: size}
+}
+
+// start starts workers of backends, which call work repeatedly until done is closed.
+func (p *workerPool) start(backends []*backend, work func(b *backend), done <-chan struct{}) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.work = work
+	p.done = done
+	p.stopChs = make(map[*backend][]chan struct{})
+	for _, b := range backends {
+		p.stopChs[b] = nil
+		p.scale(b)
+	}
+}
+
+// resize changes the number of workers of each backend, it takes effect immediately if the pool is running.
+// Workers above the new size exit once they finish the PVC they are processing or waiting for.
+func (p *workerPool) resize(size int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.size = size
+	if p.stopChs == nil {
+		return
+	}
+	for b := range p.stopChs {
+		p.scale(b)
+	}
+}
+
+// scale starts or stops workers of the backend to match the size, p.lock must be held.
+func (p *workerPool) scale(b *backend) {
+	stopChs := p.stopChs[b]
+	work, done := p.work, p.done
+	for len(stopChs) < p.size {
+		stopCh := make(chan struct{})
+		stopChs = append(stopChs, stopCh)
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for {
+				select {
+				case <-stopCh:
+					return
+				case <-done:
+					return
+				default:
+				}
+				work(b)
+			}
+		}()
+	}
+	for len(stopChs) > p.size {
+		close(stopChs[len(stopChs)-1])
+		stopChs = stopChs[:len(stopChs)-1]
+	}
+	p.stopChs[b] = stopChs
+}
+
+// wait waits for all workers to exit after done is closed, no worker is started by resize afterwards.
+func (p *workerPool) wait() {
+	p.lock.Lock()
+	p.stopChs = nil
+	p.lock.Unlock()
+	p.wg.Wait()
+}
+
+func (ctrl *resizeController) SetWorkers(workers int) error {
+	if workers <= 0 {
+		return fmt.Errorf("number of workers must be positive")
+	}
+	ctrl.logger.Info("Changing number of workers", "workers", workers)
+	ctrl.pool.resize(workers)
+	return nil
+}
+
+func (ctrl *resizeController) SetBackoff(config BackoffConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	ctrl.logger.Info("Changing backoff", "config", fmt.Sprintf("%+v", config))
+	for _, b := range ctrl.backends {
+		b.backoff.setConfig(config)
+	}
+	return nil
+}
+
+func (ctrl *resizeController) SetRateLimit(config RateLimitConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	ctrl.logger.Info("Changing rate limit", "config", fmt.Sprintf("%+v", config))
+	for _, b := range ctrl.backends {
+		b.bucket.setConfig(config)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const workqueueSubsystem = "workqueue"
+
+// workqueueMetrics are metrics of work queues of a controller, labeled by queue name. Queues of a backend
+// are renewed with the same name when the controller runs again, so metrics are looked up by name
+// rather than created per queue.
+type workqueueMetrics struct {
+	depth                   *prometheus.GaugeVec
+	adds                    *prometheus.CounterVec
+	latency                 *prometheus.HistogramVec
+	workDuration            *prometheus.HistogramVec
+	unfinishedWork          *prometheus.GaugeVec
+	longestRunningProcessor *prometheus.GaugeVec
+	retries                 *prometheus.CounterVec
+}
+
+func newWorkqueueMetrics() *workqueueMetrics {
+	return &workqueueMetrics{
+		depth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Subsystem: workqueueSubsystem,
+				Name:      "depth",
+				Help:      "Current depth of work queues, broken down by queue name.",
+			}, []string{queueNameLabel}),
+		adds: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: workqueueSubsystem,
+				Name:      "adds_total",
+				Help:      "Total number of adds handled by work queues, broken down by queue name.",
+			}, []string{queueNameLabel}),
+		latency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Subsystem: workqueueSubsystem,
+				Name:      "queue_duration_seconds",
+				Help:      "How long in seconds an item stays in work queues before being requested, broken down by queue name.",
+				Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+			}, []string{queueNameLabel}),
+		workDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Subsystem: workqueueSubsystem,
+				Name:      "work_duration_seconds",
+				Help:      "How long in seconds processing an item from work queues takes, broken down by queue name.",
+				Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+			}, []string{queueNameLabel}),
+		unfinishedWork: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Subsystem: workqueueSubsystem,
+				Name:      "unfinished_work_seconds",
+				Help: "How many seconds of work has been done that is in progress and hasn't been observed by work_duration, " +
+					"broken down by queue name.",
+			}, []string{queueNameLabel}),
+		longestRunningProcessor: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Subsystem: workqueueSubsystem,
+				Name:      "longest_running_processor_seconds",
+				Help:      "How many seconds has the longest running processor of work queues been running, broken down by queue name.",
+			}, []string{queueNameLabel}),
+		retries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: workqueueSubsystem,
+				Name:      "retries_total",
+				Help:      "Total number of retries handled by work queues, broken down by queue name.",
+			}, []string{queueNameLabel}),
+	}
+}
+
+func (m *workqueueMetrics) register(registry *prometheus.Registry) {
+	registry.MustRegister(m.depth, m.adds, m.latency, m.workDuration, m.unfinishedWork, m.longestRunningProcessor, m.retries)
+}
+
+// The work queue metrics provider can only be set once per process, so it is shared by all controllers.
+// It records metrics of a queue on the workqueueMetrics of the controller creating the queue, which is
+// set in creatingQueueMetrics while the queue is created, as the provider is called synchronously then.
+var (
+	setProviderOnce      sync.Once
+	queueCreationLock    sync.Mutex
+	creatingQueueMetrics *workqueueMetrics
+)
+
+// newNamedRateLimitingQueue creates a rate limiting queue whose metrics are recorded on m.
+// Metrics are not recorded if the embedding application sets another provider before.
+func newNamedRateLimitingQueue(
+	m *workqueueMetrics,
+	rateLimiter workqueue.RateLimiter,
+	name string) workqueue.RateLimitingInterface {
+	setProviderOnce.Do(func() {
+		workqueue.SetProvider(workqueueMetricsProvider{})
+	})
+
+	queueCreationLock.Lock()
+	defer queueCreationLock.Unlock()
+	creatingQueueMetrics = m
+	defer func() { creatingQueueMetrics = nil }()
+	return workqueue.NewNamedRateLimitingQueue(rateLimiter, name)
+}
+
+// workqueueMetricsProvider implements workqueue.MetricsProvider by metrics of the controller creating the queue.
+// Queues created by others, e.g. the embedding application, get metrics which record nothing.
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	if creatingQueueMetrics == nil {
+		return noopMetric{}
+	}
+	depth := creatingQueueMetrics.depth.WithLabelValues(name)
+	// A queue is created empty, items left in the previous queue of the same name are dropped.
+	depth.Set(0)
+	return depth
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	if creatingQueueMetrics == nil {
+		return noopMetric{}
+	}
+	return creatingQueueMetrics.adds.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	if creatingQueueMetrics == nil {
+		return noopMetric{}
+	}
+	return creatingQueueMetrics.latency.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	if creatingQueueMetrics == nil {
+		return noopMetric{}
+	}
+	return creatingQueueMetrics.workDuration.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	if creatingQueueMetrics == nil {
+		return noopMetric{}
+	}
+	return creatingQueueMetrics.unfinishedWork.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	if creatingQueueMetrics == nil {
+		return noopMetric{}
+	}
+	return creatingQueueMetrics.longestRunningProcessor.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	if creatingQueueMetrics == nil {
+		return noopMetric{}
+	}
+	return creatingQueueMetrics.retries.WithLabelValues(name)
+}
+
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Dec()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
@@ -0,0 +1,157 @@
+package csi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mlmhl/external-resizer/logging"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+)
+
+// Client is a gRPC client of a CSI driver, it only contains calls needed by the resizer.
+type Client interface {
+	// GetDriverName returns the name of the CSI driver.
+	GetDriverName(ctx context.Context) (string, error)
+
+	// SupportsPluginControllerService returns whether the CSI driver provides controller service.
+	SupportsPluginControllerService(ctx context.Context) (bool, error)
+
+	// GetVolumeExpansionCapability returns whether the CSI driver supports expanding volumes
+	// while they are published (online) or only while they are not published (offline).
+	GetVolumeExpansionCapability(ctx context.Context) (online bool, offline bool, err error)
+
+	// SupportsControllerResize returns whether the CSI driver supports ControllerExpandVolume.
+	SupportsControllerResize(ctx context.Context) (bool, error)
+
+	// Expand resizes the volume to the request size and returns the new size
+	// and whether the file system resize is required on node.
+	Expand(ctx context.Context, volumeID string, requestBytes int64, secrets map[string]string) (int64, bool, error)
+
+	// Close closes the underlying gRPC connection.
+	Close() error
+}
+
+// NewClient dials the CSI driver listening on the unix socket address and
+// waits at most timeout for the connection to be established.
+func NewClient(address string, timeout time.Duration) (Client, error) {
+	conn, err := connect(address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		conn:       conn,
+		idClient:   csi.NewIdentityClient(conn),
+		ctrlClient: csi.NewControllerClient(conn),
+	}, nil
+}
+
+type client struct {
+	conn       *grpc.ClientConn
+	idClient   csi.IdentityClient
+	ctrlClient csi.ControllerClient
+}
+
+func (c *client) GetDriverName(ctx context.Context) (string, error) {
+	rsp, err := c.idClient.GetPluginInfo(ctx, &csi.GetPluginInfoRequest{})
+	if err != nil {
+		return "", err
+	}
+	name := rsp.GetName()
+	if name == "" {
+		return "", fmt.Errorf("driver name is empty")
+	}
+	return name, nil
+}
+
+func (c *client) SupportsPluginControllerService(ctx context.Context) (bool, error) {
+	rsp, err := c.idClient.GetPluginCapabilities(ctx, &csi.GetPluginCapabilitiesRequest{})
+	if err != nil {
+		return false, err
+	}
+	for _, capability := range rsp.GetCapabilities() {
+		service := capability.GetService()
+		if service != nil && service.GetType() == csi.PluginCapability_Service_CONTROLLER_SERVICE {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *client) GetVolumeExpansionCapability(ctx context.Context) (bool, bool, error) {
+	rsp, err := c.idClient.GetPluginCapabilities(ctx, &csi.GetPluginCapabilitiesRequest{})
+	if err != nil {
+		return false, false, err
+	}
+	var online, offline bool
+	for _, capability := range rsp.GetCapabilities() {
+		expansion := capability.GetVolumeExpansion()
+		if expansion == nil {
+			continue
+		}
+		switch expansion.GetType() {
+		case csi.PluginCapability_VolumeExpansion_ONLINE:
+			online = true
+		case csi.PluginCapability_VolumeExpansion_OFFLINE:
+			offline = true
+		}
+	}
+	return online, offline, nil
+}
+
+func (c *client) SupportsControllerResize(ctx context.Context) (bool, error) {
+	rsp, err := c.ctrlClient.ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{})
+	if err != nil {
+		return false, err
+	}
+	for _, capability := range rsp.GetCapabilities() {
+		rpc := capability.GetRpc()
+		if rpc != nil && rpc.GetType() == csi.ControllerServiceCapability_RPC_EXPAND_VOLUME {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *client) Expand(
+	ctx context.Context,
+	volumeID string,
+	requestBytes int64,
+	secrets map[string]string) (int64, bool, error) {
+	req := &csi.ControllerExpandVolumeRequest{
+		VolumeId:      volumeID,
+		CapacityRange: &csi.CapacityRange{RequiredBytes: requestBytes},
+		Secrets:       secrets,
+	}
+	rsp, err := c.ctrlClient.ControllerExpandVolume(ctx, req)
+	if err != nil {
+		return 0, false, err
+	}
+	return rsp.GetCapacityBytes(), rsp.GetNodeExpansionRequired(), nil
+}
+
+func (c *client) Close() error {
+	return c.conn.Close()
+}
+
+func connect(address string, timeout time.Duration) (*grpc.ClientConn, error) {
+	address = strings.TrimPrefix(address, "unix://")
+	logging.Logger().V(3).Info("Connecting to CSI driver", "address", address)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, address,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("connect to CSI driver %s failed: %v", address, err)
+	}
+	return conn, nil
+}
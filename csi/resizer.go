@@ -0,0 +1,190 @@
+package csi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mlmhl/external-resizer/controller"
+	"github.com/mlmhl/external-resizer/logging"
+	"github.com/mlmhl/external-resizer/util"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+// tracerName is the instrumentation name of spans created by the CSI resizer.
+const tracerName = "github.com/mlmhl/external-resizer/csi"
+
+// Resizer resizes CSI volumes by calling ControllerExpandVolume of the CSI driver.
+type Resizer interface {
+	controller.ContextResizer
+
+	// DriverName returns the name of the CSI driver this resizer talks to.
+	DriverName() string
+}
+
+// NewResizer connects to the CSI driver listening on address, and makes sure
+// the driver supports expanding volumes by controller service.
+// The timeout is used both for connecting and for each CSI call. kubeClient is used to fetch
+// the Secret referenced by ControllerExpandSecretRef of PVs, it may be nil if no PV references one.
+func NewResizer(address string, timeout time.Duration, kubeClient kubernetes.Interface) (Resizer, error) {
+	client, err := NewClient(address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return newResizer(client, timeout, kubeClient)
+}
+
+func newResizer(client Client, timeout time.Duration, kubeClient kubernetes.Interface) (Resizer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	driverName, err := client.GetDriverName(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get driver name failed: %v", err)
+	}
+
+	supportsService, err := client.SupportsPluginControllerService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("check if driver %s supports controller service failed: %v", driverName, err)
+	}
+	if !supportsService {
+		return nil, fmt.Errorf("driver %s doesn't support controller service", driverName)
+	}
+
+	supportsResize, err := client.SupportsControllerResize(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("check if driver %s supports controller resize failed: %v", driverName, err)
+	}
+	if !supportsResize {
+		return nil, fmt.Errorf("driver %s doesn't support controller resize", driverName)
+	}
+
+	logging.Logger().V(3).Info("CSI driver supports controller resize", "driver", driverName)
+
+	online, offline, err := client.GetVolumeExpansionCapability(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get volume expansion capability of driver %s failed: %v", driverName, err)
+	}
+
+	return &csiResizer{
+		name:       driverName,
+		client:     client,
+		timeout:    timeout,
+		kubeClient: kubeClient,
+		capabilities: controller.Capabilities{
+			// Drivers implemented before volume expansion capability was introduced report nothing,
+			// assume they support online expansion.
+			OnlineExpansion: online || !offline,
+			// A volume can always be expanded when it is not published.
+			OfflineExpansion: true,
+			FileSystemResize: true,
+		},
+	}, nil
+}
+
+type csiResizer struct {
+	name         string
+	client       Client
+	timeout      time.Duration
+	kubeClient   kubernetes.Interface
+	capabilities controller.Capabilities
+}
+
+func (r *csiResizer) DriverName() string {
+	return r.name
+}
+
+func (r *csiResizer) Capabilities() controller.Capabilities {
+	return r.capabilities
+}
+
+func (r *csiResizer) CanSupport(pv *v1.PersistentVolume) bool {
+	source := pv.Spec.CSI
+	if source == nil {
+		logging.Logger().V(4).Info("PV is not a CSI volume", logging.KeyPV, pv.Name)
+		return false
+	}
+	return source.Driver == r.name
+}
+
+func (r *csiResizer) Resize(ctx context.Context, req *controller.ResizeRequest) (resource.Quantity, bool, error) {
+	pv, requestSize := req.PV, req.RequestSize
+	oldSize := pv.Spec.Capacity[v1.ResourceStorage]
+
+	source := pv.Spec.CSI
+	if source == nil {
+		return oldSize, false, fmt.Errorf("PV %s is not a CSI volume", pv.Name)
+	}
+	secrets, err := r.getSecrets(req)
+	if err != nil {
+		return oldSize, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	// Use the provider of the caller's span, so that the call is traced as a child of the resize operation.
+	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "ControllerExpandVolume",
+		trace.WithAttributes(attribute.String("driver", r.name), attribute.String("volumeHandle", source.VolumeHandle)))
+	defer span.End()
+	// The logger in the context carries fields of the PVC being resized.
+	logger := logging.FromContext(ctx).WithValues("driver", r.name, "volumeHandle", source.VolumeHandle)
+	logger.V(4).Info("Calling ControllerExpandVolume")
+	newSizeBytes, nodeExpansionRequired, err := r.client.Expand(ctx, source.VolumeHandle, requestSize.Value(), secrets)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+		logger.V(4).Info("ControllerExpandVolume failed", "err", err)
+		return oldSize, false, classifyError(err)
+	}
+	logger.V(4).Info("ControllerExpandVolume succeeded", "capacityBytes", newSizeBytes,
+		"nodeExpansionRequired", nodeExpansionRequired)
+	if newSizeBytes == 0 {
+		// Some drivers don't report capacity after expansion, assume the request size is satisfied.
+		return requestSize, nodeExpansionRequired, nil
+	}
+	return *resource.NewQuantity(newSizeBytes, resource.BinarySI), nodeExpansionRequired, nil
+}
+
+// getSecrets returns secrets referenced by the StorageClass, or the Secret referenced by ControllerExpandSecretRef
+// of the PV, which is set by external-provisioner, if the StorageClass references no Secret.
+func (r *csiResizer) getSecrets(req *controller.ResizeRequest) (map[string]string, error) {
+	ref := req.PV.Spec.CSI.ControllerExpandSecretRef
+	if req.Secrets != nil || ref == nil {
+		return req.Secrets, nil
+	}
+	if r.kubeClient == nil {
+		return nil, fmt.Errorf("PV %s references expand secret %s/%s, but no kube client is provided to fetch it",
+			req.PV.Name, ref.Namespace, ref.Name)
+	}
+	return util.GetSecretData(ref, r.kubeClient)
+}
+
+// classifyError converts gRPC errors of ControllerExpandVolume to ResizeErrors by the codes defined in CSI spec.
+func classifyError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.InvalidArgument, codes.OutOfRange:
+		// The request is invalid or the capacity is not supported.
+		return controller.NewResizeError(controller.ErrorKindInfeasible, err)
+	case codes.NotFound, codes.Unimplemented:
+		return controller.NewResizeError(controller.ErrorKindTerminal, err)
+	case codes.ResourceExhausted:
+		return controller.NewResizeError(controller.ErrorKindQuotaExceeded, err)
+	case codes.FailedPrecondition:
+		// The volume is published but the driver only supports offline expansion.
+		return controller.NewResizeError(controller.ErrorKindNeedsDetach, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,230 @@
+package csi
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mlmhl/external-resizer/controller"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testDriverName = "test.csi.driver"
+
+// fakeDriver implements the identity and controller services of a CSI driver supporting expansion.
+type fakeDriver struct {
+	csi.ControllerServer
+
+	expandErr error
+	// requests are ControllerExpandVolume requests received.
+	requests []*csi.ControllerExpandVolumeRequest
+}
+
+func (d *fakeDriver) GetPluginInfo(context.Context, *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
+	return &csi.GetPluginInfoResponse{Name: testDriverName}, nil
+}
+
+func (d *fakeDriver) GetPluginCapabilities(
+	context.Context, *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
+	return &csi.GetPluginCapabilitiesResponse{
+		Capabilities: []*csi.PluginCapability{
+			{Type: &csi.PluginCapability_Service_{Service: &csi.PluginCapability_Service{
+				Type: csi.PluginCapability_Service_CONTROLLER_SERVICE}}},
+			{Type: &csi.PluginCapability_VolumeExpansion_{VolumeExpansion: &csi.PluginCapability_VolumeExpansion{
+				Type: csi.PluginCapability_VolumeExpansion_OFFLINE}}},
+		},
+	}, nil
+}
+
+func (d *fakeDriver) Probe(context.Context, *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+	return &csi.ProbeResponse{}, nil
+}
+
+func (d *fakeDriver) ControllerGetCapabilities(
+	context.Context, *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+	return &csi.ControllerGetCapabilitiesResponse{
+		Capabilities: []*csi.ControllerServiceCapability{
+			{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{
+				Type: csi.ControllerServiceCapability_RPC_EXPAND_VOLUME}}},
+		},
+	}, nil
+}
+
+func (d *fakeDriver) ControllerExpandVolume(
+	_ context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
+	d.requests = append(d.requests, req)
+	if d.expandErr != nil {
+		return nil, d.expandErr
+	}
+	return &csi.ControllerExpandVolumeResponse{
+		CapacityBytes:         req.GetCapacityRange().GetRequiredBytes(),
+		NodeExpansionRequired: true,
+	}, nil
+}
+
+// startFakeDriver serves driver on a unix socket in a temp directory and returns the socket address.
+func startFakeDriver(t *testing.T, driver *fakeDriver) string {
+	dir, err := ioutil.TempDir("", "csi-resizer-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := filepath.Join(dir, "csi.sock")
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	csi.RegisterIdentityServer(server, driver)
+	csi.RegisterControllerServer(server, driver)
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Stop()
+		os.RemoveAll(dir)
+	})
+	return "unix://" + address
+}
+
+func newTestPV(secretRef *v1.SecretReference) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv"},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{
+					Driver:                    testDriverName,
+					VolumeHandle:              "volume",
+					ControllerExpandSecretRef: secretRef,
+				},
+			},
+		},
+	}
+}
+
+func TestResize(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "expand-secret", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("expand")},
+	}
+	secretRef := &v1.SecretReference{Name: secret.Name, Namespace: secret.Namespace}
+
+	tests := []struct {
+		name        string
+		secretRef   *v1.SecretReference
+		secrets     map[string]string
+		expandErr   error
+		wantSecrets map[string]string
+		wantErrKind controller.ResizeErrorKind
+		wantErr     bool
+	}{
+		{
+			name: "no secret",
+		},
+		{
+			name:        "StorageClass secret",
+			secretRef:   secretRef,
+			secrets:     map[string]string{"key": "storage-class"},
+			wantSecrets: map[string]string{"key": "storage-class"},
+		},
+		{
+			name:        "fall back to expand secret",
+			secretRef:   secretRef,
+			wantSecrets: map[string]string{"key": "expand"},
+		},
+		{
+			name:        "infeasible",
+			expandErr:   status.Error(codes.OutOfRange, "too large"),
+			wantErrKind: controller.ErrorKindInfeasible,
+			wantErr:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			driver := &fakeDriver{expandErr: test.expandErr}
+			resizer, err := NewResizer(startFakeDriver(t, driver), 5*time.Second, fake.NewSimpleClientset(secret))
+			if err != nil {
+				t.Fatalf("NewResizer failed: %v", err)
+			}
+			if name := resizer.DriverName(); name != testDriverName {
+				t.Errorf("DriverName() = %s, want %s", name, testDriverName)
+			}
+			if capabilities := controller.GetCapabilities(resizer); capabilities.OnlineExpansion {
+				t.Errorf("OnlineExpansion is set for driver only supporting offline expansion")
+			}
+
+			pv := newTestPV(test.secretRef)
+			if !resizer.CanSupport(pv) {
+				t.Fatalf("CanSupport() = false, want true")
+			}
+			newSize, fsResizeRequired, err := resizer.Resize(context.Background(), &controller.ResizeRequest{
+				PV:          pv,
+				RequestSize: resource.MustParse("2Gi"),
+				Secrets:     test.secrets,
+			})
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Resize succeeded, want error")
+				}
+				if kind := controller.GetErrorKind(err); kind != test.wantErrKind {
+					t.Errorf("error kind = %v, want %v", kind, test.wantErrKind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resize failed: %v", err)
+			}
+			if want := resource.MustParse("2Gi"); newSize.Cmp(want) != 0 {
+				t.Errorf("new size = %s, want %s", newSize.String(), want.String())
+			}
+			if !fsResizeRequired {
+				t.Errorf("fsResizeRequired = false, want true")
+			}
+			if len(driver.requests) != 1 {
+				t.Fatalf("got %d ControllerExpandVolume requests, want 1", len(driver.requests))
+			}
+			if secrets := driver.requests[0].GetSecrets(); len(secrets) != len(test.wantSecrets) ||
+				secrets["key"] != test.wantSecrets["key"] {
+				t.Errorf("secrets = %v, want %v", secrets, test.wantSecrets)
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		code     codes.Code
+		wantKind controller.ResizeErrorKind
+	}{
+		{code: codes.InvalidArgument, wantKind: controller.ErrorKindInfeasible},
+		{code: codes.OutOfRange, wantKind: controller.ErrorKindInfeasible},
+		{code: codes.NotFound, wantKind: controller.ErrorKindTerminal},
+		{code: codes.Unimplemented, wantKind: controller.ErrorKindTerminal},
+		{code: codes.ResourceExhausted, wantKind: controller.ErrorKindQuotaExceeded},
+		{code: codes.FailedPrecondition, wantKind: controller.ErrorKindNeedsDetach},
+		{code: codes.Unavailable, wantKind: controller.ErrorKindTransient},
+		{code: codes.DeadlineExceeded, wantKind: controller.ErrorKindTransient},
+	}
+	for _, test := range tests {
+		t.Run(test.code.String(), func(t *testing.T) {
+			err := classifyError(status.Error(test.code, "expand volume failed"))
+			if kind := controller.GetErrorKind(err); kind != test.wantKind {
+				t.Errorf("kind = %s, want %s", kind, test.wantKind)
+			}
+		})
+	}
+
+	if kind := controller.GetErrorKind(classifyError(context.Canceled)); kind != controller.ErrorKindTransient {
+		t.Errorf("kind of non-gRPC error = %s, want %s", kind, controller.ErrorKindTransient)
+	}
+}
@@ -12,7 +12,12 @@ import (
 	"github.com/mlmhl/external-resizer/util"
 )
 
-const sizeFileName = "kubernetes-host-path-size"
+const (
+	sizeFileName = "kubernetes-host-path-size"
+
+	// ProvisionerName is the provisioner of HostPath volumes.
+	ProvisionerName = "kubernetes.io/host-path"
+)
 
 // This Resizer is meant for development and testing only and WILL NOT WORK in a multi-node cluster.
 // Will create a size file under host path to indicate the latest size.
@@ -21,7 +26,7 @@ func New() controller.Resizer {
 }
 
 func Name() string {
-	return util.SanitizeName("kubernetes.io/host-path")
+	return util.SanitizeName(ProvisionerName)
 }
 
 type hostPathResizer struct{}
@@ -35,6 +40,15 @@ func isDirectoryHostPath(typ *v1.HostPathType) bool {
 	return typ == nil || *typ == "" || *typ == v1.HostPathDirectory || *typ == v1.HostPathDirectoryOrCreate
 }
 
+// Capabilities declares shrink support as the size file can be rewritten to any size.
+func (h hostPathResizer) Capabilities() controller.Capabilities {
+	return controller.Capabilities{
+		OnlineExpansion:  true,
+		OfflineExpansion: true,
+		Shrink:           true,
+	}
+}
+
 func (h hostPathResizer) Resize(
 	pv *v1.PersistentVolume,
 	requestSize resource.Quantity) (resource.Quantity, bool, error) {
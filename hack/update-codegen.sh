@@ -0,0 +1,32 @@
+#!/usr/bin/env bash
+
+# Generates deepcopy functions, clientset, listers and informers of API types under apis/.
+# Requires deepcopy-gen, client-gen, lister-gen and informer-gen of k8s.io/code-generator in PATH.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+MODULE=github.com/mlmhl/external-resizer
+ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+BOILERPLATE="${ROOT}/hack/boilerplate.go.txt"
+OUTPUT=$(mktemp -d)
+trap 'rm -rf "${OUTPUT}"' EXIT
+
+deepcopy-gen --input-dirs "${MODULE}/apis/resize/v1alpha1" \
+  --output-file-base zz_generated.deepcopy \
+  --output-base "${OUTPUT}" --go-header-file "${BOILERPLATE}"
+client-gen --clientset-name versioned --input-base "" \
+  --input "${MODULE}/apis/resize/v1alpha1" \
+  --output-package "${MODULE}/client/clientset" \
+  --output-base "${OUTPUT}" --go-header-file "${BOILERPLATE}"
+lister-gen --input-dirs "${MODULE}/apis/resize/v1alpha1" \
+  --output-package "${MODULE}/client/listers" \
+  --output-base "${OUTPUT}" --go-header-file "${BOILERPLATE}"
+informer-gen --input-dirs "${MODULE}/apis/resize/v1alpha1" \
+  --versioned-clientset-package "${MODULE}/client/clientset/versioned" \
+  --listers-package "${MODULE}/client/listers" \
+  --output-package "${MODULE}/client/informers" \
+  --output-base "${OUTPUT}" --go-header-file "${BOILERPLATE}"
+
+cp -r "${OUTPUT}/${MODULE}/." "${ROOT}/"
@@ -0,0 +1,113 @@
+// Package logging provides structured loggers used by the resize controller and Resizers.
+package logging
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/go-logr/logr"
+)
+
+// Keys of fields identifying the PVC being processed, all log entries about a PVC carry them.
+const (
+	KeyPVC           = "pvc"
+	KeyPV            = "pv"
+	KeyStorageClass  = "storageClass"
+	KeyBackend       = "backend"
+	KeyAttempt       = "attempt"
+	KeyRequestedSize = "requestedSize"
+)
+
+// Supported log formats.
+const (
+	// FormatText writes logs by glog, as key="value" pairs after the message.
+	FormatText = "text"
+	// FormatJSON writes one JSON object per log entry to stderr.
+	FormatJSON = "json"
+)
+
+// Formats are the supported log formats.
+var Formats = []string{FormatText, FormatJSON}
+
+var (
+	lock          sync.RWMutex
+	defaultLogger = NewGlogLogger()
+)
+
+// New creates a logger writing logs in the format.
+func New(format string) (logr.Logger, error) {
+	switch format {
+	case "", FormatText:
+		return NewGlogLogger(), nil
+	case FormatJSON:
+		return NewJSONLogger(os.Stderr), nil
+	default:
+		return logr.Discard(), fmt.Errorf("unknown log format %q, must be one of %v", format, Formats)
+	}
+}
+
+// Logger returns the default logger, which is used by components created without a logger
+// and returned by FromContext if the context carries no logger. It writes logs by glog unless
+// SetLogger is called.
+func Logger() logr.Logger {
+	lock.RLock()
+	defer lock.RUnlock()
+	return defaultLogger
+}
+
+// SetLogger replaces the default logger, it should be called before creating any component.
+func SetLogger(logger logr.Logger) {
+	lock.Lock()
+	defer lock.Unlock()
+	defaultLogger = logger
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if there is none.
+// The context passed to Resizers carries a logger with fields of the PVC being resized,
+// so that logs of Resizers can be correlated with logs of the controller.
+func FromContext(ctx context.Context) logr.Logger {
+	if logger, err := logr.FromContext(ctx); err == nil {
+		return logger
+	}
+	return Logger()
+}
+
+// NewContext returns a copy of ctx carrying the logger.
+func NewContext(ctx context.Context, logger logr.Logger) context.Context {
+	return logr.NewContext(ctx, logger)
+}
+
+// Verbosity returns the current verbosity of all loggers, i.e. the value of the -v flag of glog.
+func Verbosity() int {
+	if f := flag.Lookup("v"); f != nil {
+		if v, err := strconv.Atoi(f.Value.String()); err == nil {
+			return v
+		}
+	}
+	return 0
+}
+
+// SetVerbosity changes the verbosity of all loggers at runtime by setting the -v flag of glog.
+func SetVerbosity(v int) error {
+	if v < 0 {
+		return fmt.Errorf("verbosity must not be negative")
+	}
+	return flag.Set("v", strconv.Itoa(v))
+}
+
+// writer serializes writes of JSON log entries.
+type writer struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (w *writer) writeLine(line string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	fmt.Fprintln(w.w, line)
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/golang/glog"
+)
+
+// NewGlogLogger creates a logger writing logs by glog, so that glog flags such as -v,
+// -logtostderr and -log_dir keep working. Info logs at level n are written if glog.V(n) is enabled.
+func NewGlogLogger() logr.Logger {
+	return logr.New(&glogSink{})
+}
+
+// NewJSONLogger creates a logger writing one JSON object per log entry to w.
+// Verbosity is still controlled by the -v flag of glog.
+func NewJSONLogger(w io.Writer) logr.Logger {
+	out := &writer{w: w}
+	return logr.New(&verbositySink{
+		LogSink: funcr.NewJSON(out.writeLine, funcr.Options{LogTimestamp: true}).GetSink(),
+	})
+}
+
+type glogSink struct {
+	name   string
+	values []interface{}
+	depth  int
+}
+
+var _ logr.CallDepthLogSink = &glogSink{}
+
+func (s *glogSink) Init(info logr.RuntimeInfo) {
+	s.depth += info.CallDepth
+}
+
+func (s *glogSink) Enabled(level int) bool {
+	return bool(glog.V(glog.Level(level)))
+}
+
+func (s *glogSink) Info(_ int, msg string, keysAndValues ...interface{}) {
+	glog.InfoDepth(s.depth+1, s.format(msg, nil, keysAndValues))
+}
+
+func (s *glogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	glog.ErrorDepth(s.depth+1, s.format(msg, err, keysAndValues))
+}
+
+func (s *glogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	clone := *s
+	clone.values = append(append([]interface{}{}, s.values...), keysAndValues...)
+	return &clone
+}
+
+func (s *glogSink) WithName(name string) logr.LogSink {
+	clone := *s
+	if clone.name != "" {
+		clone.name += "/"
+	}
+	clone.name += name
+	return &clone
+}
+
+func (s *glogSink) WithCallDepth(depth int) logr.LogSink {
+	clone := *s
+	clone.depth += depth
+	return &clone
+}
+
+// format renders the entry as `name: msg key="value" ... err="..."`.
+func (s *glogSink) format(msg string, err error, keysAndValues []interface{}) string {
+	buf := &bytes.Buffer{}
+	if s.name != "" {
+		buf.WriteString(s.name)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(msg)
+	writeKeysAndValues(buf, s.values)
+	writeKeysAndValues(buf, keysAndValues)
+	if err != nil {
+		writeKeysAndValues(buf, []interface{}{"err", err})
+	}
+	return buf.String()
+}
+
+func writeKeysAndValues(buf *bytes.Buffer, keysAndValues []interface{}) {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		fmt.Fprintf(buf, " %v=%s", keysAndValues[i], formatValue(value))
+	}
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case error:
+		return strconv.Quote(v.Error())
+	case fmt.Stringer:
+		return strconv.Quote(v.String())
+	default:
+		return fmt.Sprintf("%+v", v)
+	}
+}
+
+// verbositySink enables info logs by glog.V, so that all formats share the -v flag.
+type verbositySink struct {
+	logr.LogSink
+}
+
+func (s *verbositySink) Enabled(level int) bool {
+	return bool(glog.V(glog.Level(level)))
+}
+
+func (s *verbositySink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &verbositySink{LogSink: s.LogSink.WithValues(keysAndValues...)}
+}
+
+func (s *verbositySink) WithName(name string) logr.LogSink {
+	return &verbositySink{LogSink: s.LogSink.WithName(name)}
+}
@@ -0,0 +1,81 @@
+// Package tracing sets up OpenTelemetry tracing of the resizer.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const (
+	// ExporterNone disables tracing.
+	ExporterNone = "none"
+	// ExporterStdout writes spans to stdout in JSON format.
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans to an OTLP endpoint over HTTP.
+	ExporterOTLP = "otlp"
+)
+
+// Config configures how spans are exported.
+type Config struct {
+	// Exporter is one of ExporterNone, ExporterStdout and ExporterOTLP.
+	Exporter string
+	// OTLPEndpoint is the host:port of the OTLP HTTP receiver, used by ExporterOTLP.
+	OTLPEndpoint string
+	// OTLPInsecure disables TLS when sending spans to OTLPEndpoint.
+	OTLPInsecure bool
+	// ServiceName identifies the resizer in traces.
+	ServiceName string
+	// SampleRatio is the ratio of resize operations traced, between 0 and 1.
+	SampleRatio float64
+}
+
+// Setup creates the exporter described by config and installs a TracerProvider using it as the global one.
+// The returned function flushes pending spans and shuts the provider down, it should be called before exiting.
+func Setup(ctx context.Context, config *Config) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		if config.OTLPEndpoint == "" {
+			return nil, fmt.Errorf("OTLP endpoint can't be empty")
+		}
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.OTLPEndpoint)}
+		if config.OTLPInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter failed: %v", config.Exporter, err)
+	}
+
+	provider := NewTracerProvider(exporter, config.ServiceName, config.SampleRatio)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider creates a TracerProvider exporting spans by exporter, e.g. an in-memory
+// exporter of go.opentelemetry.io/otel/sdk/trace/tracetest in tests.
+func NewTracerProvider(exporter sdktrace.SpanExporter, serviceName string, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+}
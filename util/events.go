@@ -5,4 +5,21 @@ const (
 	VolumeResizeFailed       = "VolumeResizeFailed"
 	VolumeResizeSuccess      = "VolumeResizeSuccessful"
 	FileSystemResizeRequired = "FileSystemResizeRequired"
+	VolumeResizeDryRun       = "VolumeResizeDryRun"
+
+	VolumeResizeAborted              = "VolumeResizeAborted"
+	VolumeResizeInfeasible           = "VolumeResizeInfeasible"
+	VolumeResizeBackendQuotaExceeded = "VolumeResizeBackendQuotaExceeded"
+
+	VolumeExpansionNotAllowed = "VolumeExpansionNotAllowed"
+	VolumeShrinkRejected      = "VolumeShrinkRejected"
+	ResizePolicyViolated      = "ResizePolicyViolated"
+
+	VolumeExpansionExceedsQuota = "VolumeExpansionExceedsQuota"
+
+	VolumeExpansionNotSupported = "VolumeExpansionNotSupported"
+	WaitingForDetach            = "WaitingForDetach"
+
+	VolumeAutoscaled      = "VolumeAutoscaled"
+	VolumeAutoscaleFailed = "VolumeAutoscaleFailed"
 )
@@ -2,55 +2,148 @@ package util
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
-	"github.com/golang/glog"
+	"github.com/mlmhl/external-resizer/logging"
+
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/client-go/tools/record"
 )
 
+// LeaderElectionLockTypes are supported lock types. To migrate from one lock type to another without
+// a split-brain window, first roll out the multi-lock variant holding both of them (e.g. endpointsleases),
+// then roll out the target lock type (e.g. leases).
+var LeaderElectionLockTypes = []string{
+	resourcelock.EndpointsResourceLock,
+	resourcelock.ConfigMapsResourceLock,
+	resourcelock.LeasesResourceLock,
+	resourcelock.EndpointsLeasesResourceLock,
+	resourcelock.ConfigMapsLeasesResourceLock,
+}
+
 type LeaderElectionConfig struct {
-	Identity      string
-	LockName      string
-	Namespace     string
+	Identity  string
+	LockName  string
+	Namespace string
+	// LockType is one of LeaderElectionLockTypes, defaults to endpoints for backward compatibility.
+	LockType      string
 	RetryPeriod   time.Duration
 	LeaseDuration time.Duration
 	RenewDeadLine time.Duration
+	// ReElect makes RunAsLeader re-enter the election after leadership is lost instead of returning.
+	ReElect bool
 }
 
 func NewLeaderLock(
 	kubeClient kubernetes.Interface,
 	eventRecorder record.EventRecorder,
 	config *LeaderElectionConfig) (resourcelock.Interface, error) {
-	return resourcelock.New(resourcelock.EndpointsResourceLock,
+	lockType := config.LockType
+	if lockType == "" {
+		lockType = resourcelock.EndpointsResourceLock
+	}
+	if !isValidLockType(lockType) {
+		return nil, fmt.Errorf("unknown leader election lock type %q, must be one of %v", lockType, LeaderElectionLockTypes)
+	}
+	return resourcelock.New(lockType,
 		config.Namespace,
 		config.LockName,
 		kubeClient.CoreV1(),
+		kubeClient.CoordinationV1(),
 		resourcelock.ResourceLockConfig{
 			Identity:      config.Identity,
 			EventRecorder: eventRecorder,
 		})
 }
 
-func RunAsLeader(lock resourcelock.Interface, config *LeaderElectionConfig, startFunc func(context.Context)) {
-	leaderelection.RunOrDie(context.TODO(), leaderelection.LeaderElectionConfig{
-		Lock:          lock,
-		RetryPeriod:   config.RetryPeriod,
-		LeaseDuration: config.LeaseDuration,
-		RenewDeadline: config.RenewDeadLine,
-		Callbacks: leaderelection.LeaderCallbacks{
-			OnStartedLeading: func( ctx context.Context) {
-				glog.V(3).Info("Became leader, starting")
-				startFunc(ctx)
-			},
-			OnStoppedLeading: func() {
-				glog.Fatal("Stopped leading")
-			},
-			OnNewLeader: func(identity string) {
-				glog.V(3).Infof("Current leader: %s", identity)
+func isValidLockType(lockType string) bool {
+	for _, t := range LeaderElectionLockTypes {
+		if t == lockType {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrLeadershipLost is returned by RunAsLeader if we lost leadership and re-election is disabled.
+var ErrLeadershipLost = errors.New("leadership lost")
+
+// LeadershipLostExitCode is the exit code of processes exiting due to ErrLeadershipLost.
+const LeadershipLostExitCode = 3
+
+// RunAsLeader runs startFunc once we become the leader. The context passed to startFunc is cancelled
+// when we lose leadership, and RunAsLeader waits for startFunc to return before it re-enters the election
+// if config.ReElect is set, or returns ErrLeadershipLost otherwise. If startFunc returns an error, we give
+// up leadership and RunAsLeader returns the error. nil is returned once ctx is done.
+// Leadership changes are logged by the logger in ctx, see logging.FromContext.
+func RunAsLeader(
+	ctx context.Context,
+	lock resourcelock.Interface,
+	config *LeaderElectionConfig,
+	startFunc func(context.Context) error) error {
+	logger := logging.FromContext(ctx).WithValues("identity", config.Identity)
+	for {
+		// startFunc is started asynchronously by the leader elector, claim guarantees that we either
+		// wait for it to finish or prevent it from running at all.
+		var (
+			claim    sync.Once
+			startErr error
+		)
+		done := make(chan struct{})
+		electionCtx, cancelElection := context.WithCancel(ctx)
+
+		elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+			Lock:          lock,
+			RetryPeriod:   config.RetryPeriod,
+			LeaseDuration: config.LeaseDuration,
+			RenewDeadline: config.RenewDeadLine,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					claimed := false
+					claim.Do(func() { claimed = true })
+					if !claimed {
+						return
+					}
+					defer close(done)
+					logger.V(3).Info("Became leader, starting")
+					if err := startFunc(ctx); err != nil {
+						startErr = err
+						cancelElection()
+					}
+				},
+				OnStoppedLeading: func() {
+					logger.Info("Stopped leading")
+				},
+				OnNewLeader: func(identity string) {
+					logger.V(3).Info("New leader elected", "leader", identity)
+				},
 			},
-		},
-	})
+		})
+		if err != nil {
+			cancelElection()
+			return fmt.Errorf("invalid leader election config: %v", err)
+		}
+		elector.Run(electionCtx)
+
+		claim.Do(func() { close(done) })
+		<-done
+		cancelElection()
+
+		if startErr != nil {
+			return startErr
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		// Run only returns before ctx is done if we acquired and then lost leadership.
+		if !config.ReElect {
+			return ErrLeadershipLost
+		}
+		logger.Info("Leadership lost, re-entering leader election")
+	}
 }
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ResizeOperationAnnotation records the resize operation in progress on a PV, in JSON format of ResizeOperation.
+// It's set before the backend is called and removed together with the capacity update of the PV,
+// so it survives if the resizer dies in between.
+const ResizeOperationAnnotation = "resizer.external-resizer.io/resize-operation"
+
+// ResizeOperation describes a resize operation of a volume.
+type ResizeOperation struct {
+	// ID identifies the operation, it's kept when an interrupted operation is resumed.
+	ID         string            `json:"id"`
+	TargetSize resource.Quantity `json:"targetSize"`
+	StartTime  time.Time         `json:"startTime"`
+	Backend    string            `json:"backend"`
+	// FailureReason is set if the operation failed and shouldn't be retried, e.g. the target size is infeasible.
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// GetResizeOperation returns the resize operation recorded on the PV, or nil if not exist.
+func GetResizeOperation(pv *v1.PersistentVolume) (*ResizeOperation, error) {
+	value, ok := pv.Annotations[ResizeOperationAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	op := &ResizeOperation{}
+	if err := json.Unmarshal([]byte(value), op); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation %q of PV %s: %v", ResizeOperationAnnotation, value, pv.Name, err)
+	}
+	return op, nil
+}
+
+// SetResizeOperation records the resize operation on the PV, and returns the updated PV.
+func SetResizeOperation(
+	pv *v1.PersistentVolume,
+	op *ResizeOperation,
+	kubeClient kubernetes.Interface) (*v1.PersistentVolume, error) {
+	value, err := json.Marshal(op)
+	if err != nil {
+		return nil, fmt.Errorf("marshal resize operation failed: %v", err)
+	}
+	newPV := pv.DeepCopy()
+	if newPV.Annotations == nil {
+		newPV.Annotations = make(map[string]string)
+	}
+	newPV.Annotations[ResizeOperationAnnotation] = string(value)
+	return patchPV(pv, newPV, kubeClient)
+}
+
+// ClearResizeOperation removes the resize operation recorded on the PV.
+func ClearResizeOperation(pv *v1.PersistentVolume, kubeClient kubernetes.Interface) error {
+	if _, ok := pv.Annotations[ResizeOperationAnnotation]; !ok {
+		return nil
+	}
+	newPV := pv.DeepCopy()
+	delete(newPV.Annotations, ResizeOperationAnnotation)
+	_, err := patchPV(pv, newPV, kubeClient)
+	return err
+}
+
+func patchPV(oldPV, newPV *v1.PersistentVolume, kubeClient kubernetes.Interface) (*v1.PersistentVolume, error) {
+	patchBytes, err := getPatchData(oldPV, newPV)
+	if err != nil {
+		return nil, fmt.Errorf("can't patch PV %s as generate path data failed: %v", oldPV.Name, err)
+	}
+	updatedPV, err := kubeClient.CoreV1().PersistentVolumes().Patch(oldPV.Name, types.StrategicMergePatchType, patchBytes)
+	if err != nil {
+		return nil, fmt.Errorf("patch PV %s failed: %v", oldPV.Name, err)
+	}
+	return updatedPV, nil
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUpdatePVCapacityClearsResizeOperation(t *testing.T) {
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv"},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(pv)
+	op := &ResizeOperation{
+		ID:         "op",
+		TargetSize: resource.MustParse("2Gi"),
+		StartTime:  time.Now().UTC().Truncate(time.Second),
+		Backend:    "test",
+	}
+	pv, err := SetResizeOperation(pv, op, kubeClient)
+	if err != nil {
+		t.Fatalf("SetResizeOperation failed: %v", err)
+	}
+	recorded, err := GetResizeOperation(pv)
+	if err != nil {
+		t.Fatalf("GetResizeOperation failed: %v", err)
+	}
+	if recorded == nil || recorded.ID != op.ID || recorded.TargetSize.Cmp(op.TargetSize) != 0 || !recorded.StartTime.Equal(op.StartTime) {
+		t.Fatalf("recorded operation = %+v, want %+v", recorded, op)
+	}
+
+	// The capacity update finishes the operation.
+	if err := UpdatePVCapacity(pv, op.TargetSize, kubeClient); err != nil {
+		t.Fatalf("UpdatePVCapacity failed: %v", err)
+	}
+	pv, err = kubeClient.CoreV1().PersistentVolumes().Get(pv.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity := pv.Spec.Capacity[v1.ResourceStorage]; capacity.Cmp(op.TargetSize) != 0 {
+		t.Errorf("capacity = %s, want %s", capacity.String(), op.TargetSize.String())
+	}
+	if recorded, err := GetResizeOperation(pv); err != nil || recorded != nil {
+		t.Errorf("operation is kept after the capacity update: %+v, %v", recorded, err)
+	}
+	if GetLastResizeTime(pv).IsZero() {
+		t.Errorf("last resize time isn't recorded")
+	}
+}
+
+func TestGetResizeOperationInvalid(t *testing.T) {
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pv",
+			Annotations: map[string]string{ResizeOperationAnnotation: "{"},
+		},
+	}
+	if _, err := GetResizeOperation(pv); err == nil {
+		t.Errorf("GetResizeOperation succeeded with invalid annotation")
+	}
+}
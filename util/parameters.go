@@ -0,0 +1,81 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ParameterPrefix is the prefix of StorageClass parameters reserved by the resizer,
+	// these parameters won't be passed to Resizer.
+	ParameterPrefix = "resizer.external-resizer.io/"
+
+	// SecretNameKey and SecretNamespaceKey are StorageClass parameters referencing the Secret passed to Resizer.
+	// Both of them support templates ${pvc.name}, ${pvc.namespace} and ${pv.name}.
+	SecretNameKey      = ParameterPrefix + "secret-name"
+	SecretNamespaceKey = ParameterPrefix + "secret-namespace"
+)
+
+// GetResizerParameters returns the StorageClass parameters without the reserved ones.
+func GetResizerParameters(parameters map[string]string) map[string]string {
+	result := make(map[string]string, len(parameters))
+	for k, v := range parameters {
+		if strings.HasPrefix(k, ParameterPrefix) {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// GetSecretReference resolves the Secret referenced by StorageClass parameters,
+// returns nil if no Secret is referenced.
+func GetSecretReference(
+	parameters map[string]string,
+	pvc *v1.PersistentVolumeClaim,
+	pv *v1.PersistentVolume) (*v1.SecretReference, error) {
+	nameTemplate, hasName := parameters[SecretNameKey]
+	namespaceTemplate, hasNamespace := parameters[SecretNamespaceKey]
+	if !hasName && !hasNamespace {
+		return nil, nil
+	}
+	if !hasName || !hasNamespace {
+		return nil, fmt.Errorf("%s and %s must be specified together", SecretNameKey, SecretNamespaceKey)
+	}
+
+	replacer := strings.NewReplacer(
+		"${pvc.name}", pvc.Name,
+		"${pvc.namespace}", pvc.Namespace,
+		"${pv.name}", pv.Name)
+	ref := &v1.SecretReference{
+		Name:      replacer.Replace(nameTemplate),
+		Namespace: replacer.Replace(namespaceTemplate),
+	}
+	if errs := validation.IsDNS1123Subdomain(ref.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("%s %q resolved to invalid secret name %q: %s",
+			SecretNameKey, nameTemplate, ref.Name, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsDNS1123Label(ref.Namespace); len(errs) > 0 {
+		return nil, fmt.Errorf("%s %q resolved to invalid secret namespace %q: %s",
+			SecretNamespaceKey, namespaceTemplate, ref.Namespace, strings.Join(errs, ", "))
+	}
+	return ref, nil
+}
+
+// GetSecretData fetches the referenced Secret and returns its data.
+func GetSecretData(ref *v1.SecretReference, kubeClient kubernetes.Interface) (map[string]string, error) {
+	secret, err := kubeClient.CoreV1().Secrets(ref.Namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get secret %s/%s failed: %v", ref.Namespace, ref.Name, err)
+	}
+	data := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		data[k] = string(v)
+	}
+	return data, nil
+}
@@ -4,12 +4,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"time"
+
+	"github.com/mlmhl/external-resizer/logging"
 
 	"k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+)
+
+const (
+	// AllowVolumeShrinkAnnotation opts in volume shrink when set to "true" on a PVC or its StorageClass.
+	AllowVolumeShrinkAnnotation = "resizer.external-resizer.io/allow-volume-shrink"
+
+	// LastResizeTimeAnnotation records when the capacity of a PV was updated by the resizer last time, in RFC3339 format.
+	LastResizeTimeAnnotation = "resizer.external-resizer.io/last-resize-time"
 )
 
 var knownResizeConditions = map[v1.PersistentVolumeClaimConditionType]bool{
@@ -68,9 +83,16 @@ func PatchPVCStatus(
 	return updatedClaim, nil
 }
 
+// UpdatePVCapacity updates the capacity of the PV, records the resize time in LastResizeTimeAnnotation
+// and removes ResizeOperationAnnotation as the operation is finished.
 func UpdatePVCapacity(pv *v1.PersistentVolume, newCapacity resource.Quantity, kubeClient kubernetes.Interface) error {
 	newPV := pv.DeepCopy()
 	newPV.Spec.Capacity[v1.ResourceStorage] = newCapacity
+	if newPV.Annotations == nil {
+		newPV.Annotations = make(map[string]string)
+	}
+	newPV.Annotations[LastResizeTimeAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	delete(newPV.Annotations, ResizeOperationAnnotation)
 	patchBytes, err := getPatchData(pv, newPV)
 	if err != nil {
 		return fmt.Errorf("can't update capacity of PV %s as generate path data failed: %v", pv.Name, err)
@@ -82,6 +104,22 @@ func UpdatePVCapacity(pv *v1.PersistentVolume, newCapacity resource.Quantity, ku
 	return nil
 }
 
+// UpdatePVCRequestSize updates the requested storage size of the PVC.
+func UpdatePVCRequestSize(pvc *v1.PersistentVolumeClaim, newSize resource.Quantity, kubeClient kubernetes.Interface) error {
+	newPVC := pvc.DeepCopy()
+	newPVC.Spec.Resources.Requests[v1.ResourceStorage] = newSize
+	patchBytes, err := getPatchData(pvc, newPVC)
+	if err != nil {
+		return fmt.Errorf("can't update request size of PVC %s as generate path data failed: %v", PVCKey(pvc), err)
+	}
+	_, updateErr := kubeClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).
+		Patch(pvc.Name, types.StrategicMergePatchType, patchBytes)
+	if updateErr != nil {
+		return fmt.Errorf("update request size of PVC %s failed: %v", PVCKey(pvc), updateErr)
+	}
+	return nil
+}
+
 func getPatchData(oldObj, newObj interface{}) ([]byte, error) {
 	oldData, err := json.Marshal(oldObj)
 	if err != nil {
@@ -107,6 +145,79 @@ func HasFileSystemResizePendingCondition(pvc *v1.PersistentVolumeClaim) bool {
 	return false
 }
 
+// GetPVCCondition returns the condition of given type, or nil if not exist.
+func GetPVCCondition(pvc *v1.PersistentVolumeClaim, conditionType v1.PersistentVolumeClaimConditionType) *v1.PersistentVolumeClaimCondition {
+	for i := range pvc.Status.Conditions {
+		if pvc.Status.Conditions[i].Type == conditionType {
+			return &pvc.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// GetLastResizeTime returns the time recorded in LastResizeTimeAnnotation of the PV, or zero time if not recorded.
+func GetLastResizeTime(pv *v1.PersistentVolume) time.Time {
+	value, ok := pv.Annotations[LastResizeTimeAnnotation]
+	if !ok {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logging.Logger().Error(err, "Invalid annotation of PV", "annotation", LastResizeTimeAnnotation,
+			"value", value, logging.KeyPV, pv.Name)
+		return time.Time{}
+	}
+	return t
+}
+
+func ShrinkAllowed(annotations map[string]string) bool {
+	return annotations[AllowVolumeShrinkAnnotation] == "true"
+}
+
+// IsPodActive returns true if the pod is scheduled and not terminated, which means it may be using its volumes.
+func IsPodActive(pod *v1.Pod) bool {
+	return pod.Spec.NodeName != "" && pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed
+}
+
+// GetPodsUsingPVC returns active pods which reference the PVC.
+func GetPodsUsingPVC(pvc *v1.PersistentVolumeClaim, podLister corelisters.PodLister) ([]*v1.Pod, error) {
+	pods, err := podLister.Pods(pvc.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list pods in namespace %s failed: %v", pvc.Namespace, err)
+	}
+	var result []*v1.Pod
+	for _, pod := range pods {
+		if !IsPodActive(pod) {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc.Name {
+				result = append(result, pod)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// GetVolumeAttachments returns attachments which still attach the PV to a node.
+func GetVolumeAttachments(
+	pv *v1.PersistentVolume,
+	vaLister storagelisters.VolumeAttachmentLister) ([]*storagev1.VolumeAttachment, error) {
+	attachments, err := vaLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("list volume attachments failed: %v", err)
+	}
+	var result []*storagev1.VolumeAttachment
+	for _, va := range attachments {
+		pvName := va.Spec.Source.PersistentVolumeName
+		if pvName != nil && *pvName == pv.Name && va.Status.Attached {
+			result = append(result, va)
+		}
+	}
+	return result, nil
+}
+
 func SanitizeName(name string) string {
 	re := regexp.MustCompile("[^a-zA-Z0-9-]")
 	name = re.ReplaceAllString(name, "-")
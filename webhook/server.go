@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mlmhl/external-resizer/controller"
+	"github.com/mlmhl/external-resizer/logging"
+	"github.com/mlmhl/external-resizer/util"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultPath is the url path PVC admission reviews are served on if Config.Path is empty.
+const DefaultPath = "/validate-pvc"
+
+// Config configures the admission webhook server.
+type Config struct {
+	// Address the server listens on, e.g. ":8443".
+	Address string
+	// Path of the validating webhook, defaults to DefaultPath.
+	Path string
+	// CertFile and KeyFile are the serving certificate and key. If both are empty,
+	// a self-signed certificate for Hosts is generated, see Server.CABundle.
+	CertFile string
+	KeyFile  string
+	Hosts    []string
+	// DryRun allows requests which would be rejected and only logs them, so that the webhook
+	// doesn't affect the cluster while the controller runs in dry run mode.
+	DryRun bool
+}
+
+// Server is an HTTPS server which validates PVC updates by admission reviews, and rejects
+// resize requests which would be refused by the resize controller.
+type Server struct {
+	path      string
+	dryRun    bool
+	validator *controller.Validator
+	server    *http.Server
+	caBundle  []byte
+}
+
+// NewServer creates an admission webhook server validating PVCs by validator.
+func NewServer(config *Config, validator *controller.Validator) (*Server, error) {
+	var cert tls.Certificate
+	var caBundle []byte
+	var err error
+	if config.CertFile == "" && config.KeyFile == "" {
+		var keyPEM []byte
+		caBundle, keyPEM, err = GenerateSelfSignedCert(config.Hosts, 365*24*time.Hour)
+		if err != nil {
+			return nil, fmt.Errorf("generate self-signed certificate failed: %v", err)
+		}
+		cert, err = tls.X509KeyPair(caBundle, keyPEM)
+	} else {
+		cert, err = tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load webhook certificate failed: %v", err)
+	}
+
+	s := &Server{
+		path:      config.Path,
+		dryRun:    config.DryRun,
+		validator: validator,
+		caBundle:  caBundle,
+	}
+	if s.path == "" {
+		s.path = DefaultPath
+	}
+	mux := http.NewServeMux()
+	mux.Handle(s.path, s)
+	s.server = &http.Server{
+		Addr:      config.Address,
+		Handler:   mux,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	return s, nil
+}
+
+// CABundle returns the self-signed certificate in PEM format, which should be set as the caBundle
+// of the ValidatingWebhookConfiguration. Returns nil if the certificate is loaded from files.
+func (s *Server) CABundle() []byte {
+	return s.caBundle
+}
+
+// Run serves admission reviews until stopCh is closed.
+func (s *Server) Run(stopCh <-chan struct{}) error {
+	errCh := make(chan error, 1)
+	go func() {
+		logging.Logger().Info("Starting admission webhook server", "address", s.server.Addr, "path", s.path)
+		errCh <- s.server.ListenAndServeTLS("", "")
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("admission webhook server failed: %v", err)
+	case <-stopCh:
+	}
+
+	logging.Logger().Info("Shutting down admission webhook server")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("read request body failed: %v", err), http.StatusBadRequest)
+		return
+	}
+	review := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil {
+		http.Error(w, fmt.Sprintf("decode admission review failed: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review has no request", http.StatusBadRequest)
+		return
+	}
+
+	// Respond with the same apiVersion as the request, v1beta1 and v1 reviews share the same schema.
+	response := &admissionv1.AdmissionReview{
+		TypeMeta: review.TypeMeta,
+		Response: s.review(review.Request),
+	}
+	response.Response.UID = review.Request.UID
+	data, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("encode admission review failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		logging.Logger().Error(err, "Write admission review response failed")
+	}
+}
+
+// review allows everything except PVC updates changing the request size to one the controller would refuse.
+func (s *Server) review(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	allowed := &admissionv1.AdmissionResponse{Allowed: true}
+	if req.Kind.Kind != "PersistentVolumeClaim" || req.Operation != admissionv1.Update {
+		return allowed
+	}
+
+	pvc, oldPVC := &v1.PersistentVolumeClaim{}, &v1.PersistentVolumeClaim{}
+	if err := json.Unmarshal(req.Object.Raw, pvc); err != nil {
+		return rejected(http.StatusBadRequest, metav1.StatusReasonBadRequest, fmt.Sprintf("decode PVC failed: %v", err))
+	}
+	if err := json.Unmarshal(req.OldObject.Raw, oldPVC); err != nil {
+		return rejected(http.StatusBadRequest, metav1.StatusReasonBadRequest, fmt.Sprintf("decode old PVC failed: %v", err))
+	}
+	requestSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	oldRequestSize := oldPVC.Spec.Resources.Requests[v1.ResourceStorage]
+	if requestSize.Cmp(oldRequestSize) == 0 {
+		return allowed
+	}
+
+	rejection, err := s.validator.Validate(oldPVC, pvc)
+	if err != nil {
+		// The controller checks the request again before resizing, don't block users due to our own failures.
+		logging.Logger().Error(err, "Validate resize request failed, allow it", logging.KeyPVC, util.PVCKey(pvc))
+		return allowed
+	}
+	if rejection != nil && rejection.RetryAfter == 0 {
+		if s.dryRun {
+			logging.Logger().Info("[dry run] would reject resize request", logging.KeyPVC, util.PVCKey(pvc),
+				"reason", rejection.Reason, "message", rejection.Message)
+			return allowed
+		}
+		logging.Logger().V(3).Info("Reject resize request", logging.KeyPVC, util.PVCKey(pvc),
+			"reason", rejection.Reason, "message", rejection.Message)
+		return rejected(http.StatusForbidden, metav1.StatusReasonForbidden,
+			fmt.Sprintf("%s: %s", rejection.Reason, rejection.Message))
+	}
+	// Requests which will be allowed later are accepted, the controller defers them.
+	return allowed
+}
+
+func rejected(code int32, reason metav1.StatusReason, message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Reason:  reason,
+			Message: message,
+		},
+	}
+}
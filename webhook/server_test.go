@@ -0,0 +1,282 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	resizev1alpha1 "github.com/mlmhl/external-resizer/apis/resize/v1alpha1"
+	resizelisters "github.com/mlmhl/external-resizer/client/listers/resize/v1alpha1"
+	"github.com/mlmhl/external-resizer/controller"
+	"github.com/mlmhl/external-resizer/util"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+type fakeResizer struct{}
+
+func (fakeResizer) CanSupport(*v1.PersistentVolume) bool {
+	return true
+}
+
+func (fakeResizer) Resize(_ *v1.PersistentVolume, requestSize resource.Quantity) (resource.Quantity, bool, error) {
+	return requestSize, false, nil
+}
+
+// failingStorageClassLister fails to get any StorageClass.
+type failingStorageClassLister struct {
+	storagelisters.StorageClassLister
+}
+
+func (failingStorageClassLister) Get(name string) (*storagev1.StorageClass, error) {
+	return nil, fmt.Errorf("cache of StorageClass %s is broken", name)
+}
+
+// startServer runs a webhook server with a self-signed certificate, and returns the url of the webhook
+// and a client trusting the certificate.
+func startServer(t *testing.T, validator *controller.Validator, dryRun bool) (string, *http.Client) {
+	// Reserve a free port for the server.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server, err := NewServer(&Config{Address: address, Hosts: []string{"127.0.0.1"}, DryRun: dryRun}, validator)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	stopCh := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Run(stopCh)
+	}()
+	t.Cleanup(func() {
+		close(stopCh)
+		if err := <-errCh; err != nil {
+			t.Errorf("server stopped with error: %v", err)
+		}
+	})
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(server.CABundle()) {
+		t.Fatalf("invalid CA bundle")
+	}
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		Timeout:   5 * time.Second,
+	}
+	url := "https://" + address + DefaultPath
+
+	// Wait for the server to listen.
+	for i := 0; ; i++ {
+		rsp, err := client.Get(url)
+		if err == nil {
+			rsp.Body.Close()
+			break
+		}
+		if i == 50 {
+			t.Fatalf("server is not ready: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return url, client
+}
+
+func newValidator(
+	t *testing.T,
+	scLister storagelisters.StorageClassLister,
+	policies []*resizev1alpha1.ResizePolicy) *controller.Validator {
+	registry := controller.NewRegistry()
+	if err := registry.Register(controller.Backend{
+		Name:    "test",
+		Resizer: controller.NewContextResizer(fakeResizer{}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pvIndexer.Add(&v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{
+		Name: "pv",
+		// The volume was just resized.
+		Annotations: map[string]string{util.LastResizeTimeAnnotation: time.Now().UTC().Format(time.RFC3339)},
+	}})
+	policyIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, policy := range policies {
+		policyIndexer.Add(policy)
+	}
+	return controller.NewValidator(registry, corelisters.NewPersistentVolumeLister(pvIndexer), scLister,
+		resizelisters.NewResizePolicyLister(policyIndexer))
+}
+
+func newResizePolicy(name string, spec resizev1alpha1.ResizePolicySpec) *resizev1alpha1.ResizePolicy {
+	return &resizev1alpha1.ResizePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       spec,
+	}
+}
+
+func quantityPtr(value string) *resource.Quantity {
+	q := resource.MustParse(value)
+	return &q
+}
+
+func newStorageClassLister(allowVolumeExpansion bool) storagelisters.StorageClassLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(&storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: "sc"},
+		AllowVolumeExpansion: &allowVolumeExpansion,
+	})
+	return storagelisters.NewStorageClassLister(indexer)
+}
+
+func newPVC(requestSize string) *v1.PersistentVolumeClaim {
+	scName := "sc"
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "default"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &scName,
+			VolumeName:       "pv",
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(requestSize)},
+			},
+		},
+		Status: v1.PersistentVolumeClaimStatus{
+			Phase:    v1.ClaimBound,
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+		},
+	}
+}
+
+// review posts an admission review of updating oldPVC to pvc and returns the response.
+func review(t *testing.T, url string, client *http.Client, oldPVC, pvc *v1.PersistentVolumeClaim) *admissionv1.AdmissionResponse {
+	raw := func(pvc *v1.PersistentVolumeClaim) runtime.RawExtension {
+		data, err := json.Marshal(pvc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runtime.RawExtension{Raw: data}
+	}
+	request := &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("uid"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+			Operation: admissionv1.Update,
+			Object:    raw(pvc),
+			OldObject: raw(oldPVC),
+		},
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("post admission review failed: %v", err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", rsp.StatusCode, http.StatusOK)
+	}
+	response := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(rsp.Body).Decode(response); err != nil {
+		t.Fatalf("decode admission review failed: %v", err)
+	}
+	if response.Response == nil {
+		t.Fatalf("admission review has no response")
+	}
+	if response.Response.UID != request.Request.UID {
+		t.Errorf("response UID = %s, want %s", response.Response.UID, request.Request.UID)
+	}
+	return response.Response
+}
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		scLister    storagelisters.StorageClassLister
+		policies    []*resizev1alpha1.ResizePolicy
+		requestSize string
+		dryRun      bool
+		wantAllowed bool
+	}{
+		{
+			name:        "size unchanged",
+			scLister:    newStorageClassLister(false),
+			requestSize: "1Gi",
+			wantAllowed: true,
+		},
+		{
+			name:        "expansion allowed",
+			scLister:    newStorageClassLister(true),
+			requestSize: "2Gi",
+			wantAllowed: true,
+		},
+		{
+			name:        "expansion not allowed",
+			scLister:    newStorageClassLister(false),
+			requestSize: "2Gi",
+			wantAllowed: false,
+		},
+		{
+			name:        "expansion not allowed in dry run",
+			scLister:    newStorageClassLister(false),
+			requestSize: "2Gi",
+			dryRun:      true,
+			wantAllowed: true,
+		},
+		{
+			name:     "min interval of policy not elapsed",
+			scLister: newStorageClassLister(true),
+			policies: []*resizev1alpha1.ResizePolicy{
+				newResizePolicy("min-interval", resizev1alpha1.ResizePolicySpec{MinInterval: &metav1.Duration{Duration: time.Hour}}),
+			},
+			requestSize: "2Gi",
+			wantAllowed: true,
+		},
+		{
+			name:     "max size of policy exceeded while min interval not elapsed",
+			scLister: newStorageClassLister(true),
+			policies: []*resizev1alpha1.ResizePolicy{
+				newResizePolicy("min-interval", resizev1alpha1.ResizePolicySpec{MinInterval: &metav1.Duration{Duration: time.Hour}}),
+				newResizePolicy("max-size", resizev1alpha1.ResizePolicySpec{MaxSize: quantityPtr("1Gi")}),
+			},
+			requestSize: "2Gi",
+			wantAllowed: false,
+		},
+		{
+			name:        "validation error",
+			scLister:    failingStorageClassLister{},
+			requestSize: "2Gi",
+			wantAllowed: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url, client := startServer(t, newValidator(t, test.scLister, test.policies), test.dryRun)
+			response := review(t, url, client, newPVC("1Gi"), newPVC(test.requestSize))
+			if response.Allowed != test.wantAllowed {
+				t.Fatalf("allowed = %v, want %v", response.Allowed, test.wantAllowed)
+			}
+			if !test.wantAllowed && (response.Result == nil || response.Result.Code != http.StatusForbidden) {
+				t.Errorf("result = %+v, want code %d", response.Result, http.StatusForbidden)
+			}
+		})
+	}
+}